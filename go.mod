@@ -3,8 +3,9 @@ module multiple-recall
 go 1.22.6
 
 require (
-	github.com/jmoiron/sqlx v1.4.0 // indirect
-	github.com/mattn/go-sqlite3 v1.14.24 // indirect
-	github.com/mozillazg/go-pinyin v0.20.0 // indirect
-	github.com/yanyiwu/gojieba v1.4.4 // indirect
+	github.com/jmoiron/sqlx v1.4.0
+	github.com/mattn/go-sqlite3 v1.14.24
+	github.com/mozillazg/go-pinyin v0.20.0
 )
+
+require github.com/yanyiwu/gojieba v1.4.4 // indirect
@@ -39,7 +39,7 @@ func main() {
 	// dict_dir := filepath.Join(base_dir, "dict")
 	// index_dir := filepath.Join(base_dir, "index")
 	// index_name := ""
-	// index_path, err := radix.NewIndex(dict_dir, index_dir, index_name, 2, 100)
+	// index_path, err := radix.NewIndex(dict_dir, index_dir, index_name, radix.DefaultIndexOptions())
 	// if err != nil {
 	// 	log.Printf("Error creating index: %v\n", err)
 	// } else {
@@ -47,7 +47,7 @@ func main() {
 	// }
 
 	index_path := filepath.Join(base_dir, "index", "1122.bin")
-	_, err = radix.DebugIndex(index_path, 2, 100)
+	_, err = radix.DebugIndex(index_path)
 	if err != nil {
 		log.Printf("Error creating index: %v\n", err)
 	}
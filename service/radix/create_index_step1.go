@@ -3,7 +3,11 @@ package radix
 // 从字典文件中读取词条并插入数据库
 
 import (
+	"database/sql"
 	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
@@ -14,11 +18,11 @@ import (
 	"github.com/jmoiron/sqlx"
 )
 
-func list_dicts(dirPath string) []string {
+func list_dicts(dirPath string) ([]string, error) {
 	// 读取目录
 	dirs, err := os.ReadDir(dirPath)
 	if err != nil {
-		return make([]string, 0)
+		return nil, fmt.Errorf("failed to read dict dir: %w", err)
 	}
 
 	// 过滤出 .dict 后缀的文件
@@ -31,30 +35,41 @@ func list_dicts(dirPath string) []string {
 			}
 		}
 	}
-	return dicts
+	return dicts, nil
 }
 
-func step1_proc_read_csv_dict_words(dictName string, filePath string, recordCh chan<- []DictWord) {
+// 读取一个字典文件，格式错误的行记录日志后跳过；读取文件失败时返回错误，已发送的批次由写入协程丢弃
+func step1_proc_read_csv_dict_words(dictName string, filePath string, b *index_build, recordCh chan<- []DictWord) error {
+	batchSize := b.opts.WriteBatch
 	start_time := time.Now().UnixMilli()
 	count := 0
 	csvFile, err := os.Open(filePath)
 	if err != nil {
-		log.Printf("无法打开文件 %s: %v", filePath, err)
-		return
+		return fmt.Errorf("failed to open dict file: %w", err)
 	}
 	defer csvFile.Close()
 
 	reader := csv.NewReader(csvFile)
-	_, _ = reader.Read() // 跳过标题行
-	batch := make([]DictWord, 0, 1000)
+	if _, err := reader.Read(); err != nil && !errors.Is(err, io.EOF) && !_step1_is_csv_parse_error(err) { // 跳过标题行
+		return fmt.Errorf("failed to read dict file %s: %w", filePath, err)
+	}
+	batch := make([]DictWord, 0, batchSize)
 
-	for {
+	for !b.canceled() {
 		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if _step1_is_csv_parse_error(err) {
+			log.Printf("读取文件 %s 失败，跳过该行: %v", filePath, err)
+			continue
+		}
 		if err != nil {
-			if err.Error() == "EOF" {
-				break
-			}
-			log.Printf("读取文件 %s 失败: %v", filePath, err)
+			return fmt.Errorf("failed to read dict file %s: %w", filePath, err)
+		}
+		if len(record) < 2 {
+			line, _ := reader.FieldPos(0)
+			log.Printf("文件 %s 第 %d 行缺少 data 列，跳过该行", filePath, line)
 			continue
 		}
 
@@ -76,65 +91,92 @@ func step1_proc_read_csv_dict_words(dictName string, filePath string, recordCh c
 			WordPinyin: sentence.ToPinyin(),
 		})
 
-		if len(batch) >= 1000 { // 每1000条发送一次
+		if len(batch) >= batchSize { // 每 batchSize 条发送一次
 			count += len(batch)
 			log.Printf("从字典[%s]读取 %d 条记录", dictName, len(batch))
-			recordCh <- batch
-			batch = make([]DictWord, 0, 1000) // 清空批量缓存
+			b.advance(len(batch))
+			if !send_batch(b.ctx, recordCh, batch) {
+				return nil
+			}
+			batch = make([]DictWord, 0, batchSize) // 清空批量缓存
 		}
 	}
 
-	if len(batch) > 0 { // 发送剩余不足1000的记录
+	if len(batch) > 0 && !b.canceled() { // 发送剩余不足 batchSize 的记录
 		count += len(batch)
-		recordCh <- batch
+		b.advance(len(batch))
+		if !send_batch(b.ctx, recordCh, batch) {
+			return nil
+		}
 	}
 
 	log.Printf("字典[%s]读取完成，共 %d 条记录，耗时 %d 毫秒", dictName, count, time.Now().UnixMilli()-start_time)
+	return nil
 }
 
-func step1_proc_write_dict_words(db *sqlx.DB, recordCh <-chan []DictWord) (int, int) {
+// CSV 格式错误，只影响出错的行
+func _step1_is_csv_parse_error(err error) bool {
+	var parse_err *csv.ParseError
+	return errors.As(err, &parse_err)
+}
+
+func step1_proc_write_dict_words(db *sqlx.DB, b *index_build, recordCh <-chan []DictWord) (int, int) {
 	read := 0
 	count := 0
 	for batch := range recordCh {
+		if b.canceled() { // 已取消时丢弃剩余批次，等待读取协程退出后通道关闭
+			continue
+		}
 		read += len(batch)
 		tx, err := db.Begin() // 开启事务
 		if err != nil {
-			log.Printf("事务开启失败: %v", err)
+			b.fail(fmt.Errorf("failed to begin transaction: %w", err))
 			continue
 		}
-
-		stmt, err := tx.Prepare("INSERT INTO dict_words (dict, name, data, word_chars, word_pinyin) VALUES (?, ?, ?, ?, ?)")
+		inserted, err := _step1_insert_dict_words(tx, batch)
 		if err != nil {
-			log.Printf("准备语句失败: %v", err)
 			tx.Rollback()
+			b.fail(err)
 			continue
 		}
-
-		for _, rec := range batch {
-			_, err := stmt.Exec(rec.Dict, rec.Name, rec.Data, rec.WordChars, rec.WordPinyin)
-			if err != nil {
-				log.Printf("插入记录失败: %v", err)
-			} else {
-				count += 1
-			}
-		}
-		stmt.Close()
-
 		if err := tx.Commit(); err != nil {
-			log.Printf("事务提交失败: %v", err)
-			tx.Rollback()
-		} else {
-			log.Printf("成功插入 %d 条记录\n", len(batch))
-			tx.Commit()
+			b.fail(fmt.Errorf("failed to commit dict_words: %w", err))
+			continue
 		}
+		count += inserted
+		log.Printf("成功插入 %d 条记录\n", inserted)
+		b.report()
 	}
 	return read, count
 }
 
-func step1_main_collect_dict_words(db *sqlx.DB, dict_dir string) (int, int) {
-	dicts := list_dicts(dict_dir)
+// 插入一批字典词，插入失败的词条记录日志后跳过，返回成功插入的数量
+func _step1_insert_dict_words(tx *sql.Tx, batch []DictWord) (int, error) {
+	stmt, err := tx.Prepare("INSERT INTO dict_words (dict, name, data, word_chars, word_pinyin) VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare dict_words insert: %w", err)
+	}
+	defer stmt.Close()
+	count := 0
+	for _, rec := range batch {
+		if _, err := stmt.Exec(rec.Dict, rec.Name, rec.Data, rec.WordChars, rec.WordPinyin); err != nil {
+			log.Printf("插入记录 %s 失败: %v", rec.Name, err)
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+// 读取字典目录下全部 .csv 字典文件，读取文件或数据库操作失败时返回错误
+func step1_main_collect_dict_words(db *sqlx.DB, dict_dir string, b *index_build) (int, int, error) {
+	dicts, err := list_dicts(dict_dir)
+	if err != nil {
+		return 0, 0, err
+	}
 	if len(dicts) == 0 {
-		return 0, 0
+		log.Printf("字典目录 %s 中没有 .csv 字典文件", dict_dir)
+		return 0, 0, nil
 	}
 
 	recordCh := make(chan []DictWord, 10) // 用于传输批量记录
@@ -145,7 +187,9 @@ func step1_main_collect_dict_words(db *sqlx.DB, dict_dir string) (int, int) {
 		wg.Add(1)
 		go func(dict string, csv_path string) {
 			defer wg.Done()
-			step1_proc_read_csv_dict_words(dict, csv_path, recordCh)
+			if err := step1_proc_read_csv_dict_words(dict, csv_path, b, recordCh); err != nil {
+				b.fail(err)
+			}
 		}(dictName, filepath.Join(dict_dir, dictName+".csv"))
 	}
 
@@ -156,5 +200,6 @@ func step1_main_collect_dict_words(db *sqlx.DB, dict_dir string) (int, int) {
 	}()
 
 	// 用当前协程来写入数据库
-	return step1_proc_write_dict_words(db, recordCh)
+	read, count := step1_proc_write_dict_words(db, b, recordCh)
+	return read, count, b.err()
 }
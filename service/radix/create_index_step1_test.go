@@ -0,0 +1,76 @@
+package radix
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func step1_test_dict_names(t *testing.T, index_path string) []string {
+	t.Helper()
+	db := open_test_indexdb(t, index_path)
+	var names []string
+	if err := db.Select(&names, "SELECT name FROM dict_words"); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 字典文件中格式错误的行记录日志后跳过，其余词条正常创建索引
+func TestStep1MalformedCSV(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+	}{
+		{"bare quote", "name,data\n中国银行,{}\n哆啦\"A梦,{}\n银行大厦,{}\n"},
+		{"extra field", "name,data\n中国银行,{}\n长沙市,{},x\n银行大厦,{}\n"},
+		{"missing data", "name,data\n中国银行,{}\n长沙市\n银行大厦,{}\n"},
+	}
+	for _, c := range cases {
+		dict_dir := filepath.Join(t.TempDir(), "dict")
+		if err := os.MkdirAll(dict_dir, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dict_dir, "places.csv"), []byte(c.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		index_path, err := NewIndex(dict_dir, filepath.Join(t.TempDir(), "index"), "test", nil)
+		if err != nil {
+			t.Errorf("%s: NewIndex returned %v", c.name, err)
+			continue
+		}
+		if names := step1_test_dict_names(t, index_path); !slices.Equal(names, []string{"中国银行", "银行大厦"}) {
+			t.Errorf("%s: dict_words = %v", c.name, names)
+		}
+	}
+}
+
+// 插入失败的词条跳过；没有字典文件时创建空索引，字典目录无法读取时失败
+func TestStep1SkipsFailedInserts(t *testing.T) {
+	index_path := build_places_index(t, nil)
+	db := open_test_indexdb(t, index_path)
+	if err := write_index_meta(db, metaIndexStep, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("CREATE TRIGGER fail_insert BEFORE INSERT ON dict_words WHEN NEW.name = '长沙市' BEGIN SELECT RAISE(ABORT, 'insert refused'); END"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ResumeIndex(index_path); err != nil {
+		t.Fatal(err)
+	}
+	names := step1_test_dict_names(t, index_path)
+	if len(names) != len(testPlaces)-1 || slices.Contains(names, "长沙市") {
+		t.Errorf("dict_words = %v, want every place except 长沙市", names)
+	}
+
+	if _, err := NewIndex(t.TempDir(), filepath.Join(t.TempDir(), "index"), "test", nil); err != nil {
+		t.Errorf("NewIndex without any dict file returned %v", err)
+	}
+	if _, err := NewIndex(filepath.Join(t.TempDir(), "missing"), filepath.Join(t.TempDir(), "index"), "test", nil); err == nil {
+		t.Error("NewIndex succeeded with a missing dict dir")
+	}
+}
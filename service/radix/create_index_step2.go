@@ -3,6 +3,8 @@ package radix
 // 从字典词条的 word_chars 里，收集重复的中文前缀、后缀；要求至少两个及以上连续的汉字
 
 import (
+	"database/sql"
+	"fmt"
 	"log"
 	"strings"
 	"sync"
@@ -65,29 +67,30 @@ func findCommonPrefixesAndSuffixes(words []string, minFreq int) (map[string]int,
 	return commonPrefixes, commonSuffixes
 }
 
-func _step2_list_distinct_dicts(db *sqlx.DB) []string {
+func _step2_list_distinct_dicts(db *sqlx.DB) ([]string, error) {
 	var dicts []string
 	err := db.Select(&dicts, "SELECT DISTINCT dict FROM dict_words")
 	if err != nil {
-		log.Printf("Query failed: %v", err)
-		return make([]string, 0)
+		return nil, fmt.Errorf("failed to query dicts: %w", err)
 	}
-	return dicts
+	return dicts, nil
 }
 
-func _step2_collect_dict_chinese_words(db *sqlx.DB, dict string) []string {
+func _step2_collect_dict_chinese_words(db *sqlx.DB, dict string, b *index_build) ([]string, error) {
 	idrange := getTableRange(db, "dict_words", "where dict = '"+dict+"'")
 	if idrange.Count == 0 {
-		return make([]string, 0)
+		return make([]string, 0), nil
 	}
 	words := make(map[string]bool)
-	subs := idrange.Split(5000, 0)
+	subs := idrange.Split(5000, b.opts.Workers)
 	for _, sub := range subs {
+		if b.canceled() {
+			break
+		}
 		var dict_words []string
 		err := db.Select(&dict_words, "SELECT word_chars FROM dict_words WHERE dict = ? AND id >= ? AND id <= ?", dict, sub.MinId, sub.MaxId)
 		if err != nil {
-			log.Printf("Query failed: %v", err)
-			continue
+			return nil, fmt.Errorf("failed to query dict_words of %s: %w", dict, err)
 		}
 		for _, word := range dict_words {
 			split_words := strings.Split(word, "|")
@@ -103,97 +106,116 @@ func _step2_collect_dict_chinese_words(db *sqlx.DB, dict string) []string {
 		results = append(results, word)
 	}
 	log.Printf("从字典[%s]中读取 %d 条词条，用于计算高频前缀后缀；\n", dict, len(results))
-	return results
+	return results, nil
 }
 
-func step2_proc_collect_dict_word_repeats(db *sqlx.DB, dict string, minFreq int, recordCh chan<- []DictWordRepeat) {
-	words := _step2_collect_dict_chinese_words(db, dict)
-	commonPrefixes, commonSuffixes := findCommonPrefixesAndSuffixes(words, minFreq)
+func step2_proc_collect_dict_word_repeats(db *sqlx.DB, dict string, b *index_build, recordCh chan<- []DictWordRepeat) error {
+	opts := b.opts
+	words, err := _step2_collect_dict_chinese_words(db, dict, b)
+	if err != nil {
+		return err
+	}
+	if b.canceled() {
+		return nil
+	}
+	commonPrefixes, commonSuffixes := findCommonPrefixesAndSuffixes(words, opts.RepeatMinFreq)
 
-	batch := make([]DictWordRepeat, 1000)
-	count := 0
+	batch := make([]DictWordRepeat, 0, opts.WriteBatch)
 	for k, v := range commonPrefixes {
 		batch = append(batch, DictWordRepeat{Dict: dict, Type: 0, Word: k, WordLen: len([]rune(k)), RepeatCount: int(v)})
-		count++
 
 		// 如果达到批次大小，则加入批次，并清空当前 batch
-		if count >= 1000 {
-			recordCh <- batch
-			batch = make([]DictWordRepeat, 1000)
-			count = 0
+		if len(batch) >= opts.WriteBatch {
+			if !send_batch(b.ctx, recordCh, batch) {
+				return nil
+			}
+			batch = make([]DictWordRepeat, 0, opts.WriteBatch)
 		}
 	}
 
 	// 添加最后一批（如果有剩余）
-	if count > 0 {
-		recordCh <- batch
+	if len(batch) > 0 && !send_batch(b.ctx, recordCh, batch) {
+		return nil
 	}
 
-	batch = make([]DictWordRepeat, 1000)
-	count = 0
+	batch = make([]DictWordRepeat, 0, opts.WriteBatch)
 	for k, v := range commonSuffixes {
 		batch = append(batch, DictWordRepeat{Dict: dict, Type: 1, Word: k, WordLen: len([]rune(k)), RepeatCount: int(v)})
-		count++
 
 		// 如果达到批次大小，则加入批次，并清空当前 batch
-		if count >= 1000 {
-			recordCh <- batch
-			batch = make([]DictWordRepeat, 1000)
-			count = 0
+		if len(batch) >= opts.WriteBatch {
+			if !send_batch(b.ctx, recordCh, batch) {
+				return nil
+			}
+			batch = make([]DictWordRepeat, 0, opts.WriteBatch)
 		}
 	}
 
 	// 添加最后一批（如果有剩余）
-	if count > 0 {
-		recordCh <- batch
+	if len(batch) > 0 && !send_batch(b.ctx, recordCh, batch) {
+		return nil
 	}
+	b.advance(1)
+	return nil
 }
 
-func step2_proc_write_dict_word_repeats(db *sqlx.DB, recordCh <-chan []DictWordRepeat) int {
+func step2_proc_write_dict_word_repeats(db *sqlx.DB, b *index_build, recordCh <-chan []DictWordRepeat) int {
 	count := 0
 	for batch := range recordCh {
+		if b.canceled() { // 已取消时丢弃剩余批次，等待读取协程退出后通道关闭
+			continue
+		}
 		tx, err := db.Begin() // 开启事务
 		if err != nil {
-			log.Printf("事务开启失败: %v", err)
+			b.fail(fmt.Errorf("failed to begin transaction: %w", err))
 			continue
 		}
-
-		stmt, err := tx.Prepare("INSERT INTO dict_word_repeats (dict, type, word, word_len, repeat_count) VALUES (?, ?, ?, ?, ?)")
+		inserted, err := _step2_insert_dict_word_repeats(tx, batch)
 		if err != nil {
-			log.Printf("准备语句失败: %v", err)
 			tx.Rollback()
+			b.fail(err)
 			continue
 		}
-
-		for _, rec := range batch {
-			if rec.Dict == "" || rec.RepeatCount <= 0 || rec.WordLen < 2 {
-				continue
-			}
-			_, err := stmt.Exec(rec.Dict, rec.Type, rec.Word, rec.WordLen, rec.RepeatCount)
-			if err != nil {
-				log.Printf("插入记录失败: %v", err)
-			} else {
-				count += 1
-			}
-		}
-		stmt.Close()
-
 		if err := tx.Commit(); err != nil {
-			log.Printf("事务提交失败: %v", err)
-			tx.Rollback()
-		} else {
-			tx.Commit()
-			log.Printf("成功插入 %d 条记录\n", len(batch))
+			b.fail(fmt.Errorf("failed to commit dict_word_repeats: %w", err))
+			continue
 		}
+		count += inserted
+		log.Printf("成功插入 %d 条记录\n", len(batch))
+		b.report()
 	}
 	return count
 }
 
-func step2_main_collect_word_repeat_parts(db *sqlx.DB) int {
-	dicts := _step2_list_distinct_dicts(db)
+func _step2_insert_dict_word_repeats(tx *sql.Tx, batch []DictWordRepeat) (int, error) {
+	stmt, err := tx.Prepare("INSERT INTO dict_word_repeats (dict, type, word, word_len, repeat_count) VALUES (?, ?, ?, ?, ?)")
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare dict_word_repeats insert: %w", err)
+	}
+	defer stmt.Close()
+
+	count := 0
+	for _, rec := range batch {
+		if rec.Dict == "" || rec.RepeatCount <= 0 || rec.WordLen < 2 {
+			continue
+		}
+		if _, err := stmt.Exec(rec.Dict, rec.Type, rec.Word, rec.WordLen, rec.RepeatCount); err != nil {
+			return count, fmt.Errorf("failed to insert dict word repeat %q: %w", rec.Word, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+func step2_main_collect_word_repeat_parts(db *sqlx.DB, b *index_build) (int, error) {
+	dicts, err := _step2_list_distinct_dicts(db)
+	if err != nil {
+		return 0, err
+	}
 	if len(dicts) == 0 {
-		return 0
+		return 0, nil
 	}
+	b.set_total(len(dicts))
 
 	// 创建通道
 	recordCh := make(chan []DictWordRepeat, 10)
@@ -202,8 +224,10 @@ func step2_main_collect_word_repeat_parts(db *sqlx.DB) int {
 	for _, dict := range dicts {
 		wg.Add(1)
 		go func(name string) {
-			step2_proc_collect_dict_word_repeats(db, name, 10, recordCh)
-			wg.Done()
+			defer wg.Done()
+			if err := step2_proc_collect_dict_word_repeats(db, name, b, recordCh); err != nil {
+				b.fail(err)
+			}
 		}(dict)
 	}
 
@@ -214,5 +238,6 @@ func step2_main_collect_word_repeat_parts(db *sqlx.DB) int {
 	}()
 
 	// 用当前主协程处理读取的字典词
-	return step2_proc_write_dict_word_repeats(db, recordCh)
+	count := step2_proc_write_dict_word_repeats(db, b, recordCh)
+	return count, b.err()
 }
@@ -0,0 +1,84 @@
+package radix
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 在临时目录中创建空的索引数据库
+func new_test_indexdb(t *testing.T) *sqlx.DB {
+	t.Helper()
+	db, err := initialize_indexdb(filepath.Join(t.TempDir(), "test.bin"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func insert_test_dict_words(t *testing.T, db *sqlx.DB, dict string, names []string) {
+	t.Helper()
+	for _, name := range names {
+		sentence := NewIndexSentence(name)
+		_, err := db.Exec("INSERT INTO dict_words (dict, name, data, word_chars, word_pinyin) VALUES (?, ?, ?, ?, ?)", dict, name, "{}", sentence.ToString(), sentence.ToPinyin())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestStep2RepeatBatches(t *testing.T) {
+	db := new_test_indexdb(t)
+	names := make([]string, 0)
+	for _, n := range []string{"一", "二", "三", "四", "五"} {
+		names = append(names, fmt.Sprintf("宝宝洗发沐浴露%s号", n))
+	}
+	insert_test_dict_words(t, db, "goods", names)
+
+	opts := DefaultIndexOptions()
+	opts.RepeatMinFreq = 2
+	opts.WriteBatch = 2
+	b := new_index_build(context.Background(), opts, nil)
+	words, err := _step2_collect_dict_chinese_words(db, "goods", b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefixes, suffixes := findCommonPrefixesAndSuffixes(words, opts.RepeatMinFreq)
+	if len(prefixes)+len(suffixes) <= opts.WriteBatch {
+		t.Fatalf("expected more than one batch, got %d prefixes and %d suffixes", len(prefixes), len(suffixes))
+	}
+
+	recordCh := make(chan []DictWordRepeat, 100)
+	if err := step2_proc_collect_dict_word_repeats(db, "goods", b, recordCh); err != nil {
+		t.Fatal(err)
+	}
+	close(recordCh)
+
+	total := 0
+	for batch := range recordCh {
+		if len(batch) == 0 || len(batch) > opts.WriteBatch {
+			t.Errorf("batch size %d, want 1..%d", len(batch), opts.WriteBatch)
+		}
+		for _, r := range batch {
+			if r.Dict != "goods" || r.Word == "" || r.RepeatCount < opts.RepeatMinFreq {
+				t.Errorf("unexpected repeat row %+v", r)
+			}
+		}
+		total += len(batch)
+	}
+	if total != len(prefixes)+len(suffixes) {
+		t.Errorf("sent %d repeat rows, want %d", total, len(prefixes)+len(suffixes))
+	}
+
+	count, err := step2_main_collect_word_repeat_parts(db, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != total {
+		t.Errorf("wrote %d repeat rows, want %d", count, total)
+	}
+}
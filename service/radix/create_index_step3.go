@@ -1,8 +1,9 @@
 package radix
 
-// 根据字典词，去除其中的高频前缀后缀，创建多维度索引词，包括：字符、拼音、拼音缩写
+// 根据字典词，去除其中的高频前缀后缀，创建多维度索引词，包括：字符(Type 0)、拼音(Type 1)、拼音首字母(Type 2)、模糊拼音(Type 3)
 
 import (
+	"fmt"
 	"log"
 	"strings"
 	"sync"
@@ -21,62 +22,112 @@ func _step3_calc_index_word_weight(word string) int {
 	return 1
 }
 
-func _step3_split_dict_word_to_index_words(dictWords []DictWord, dictPrefixs map[string][]string, dictSuffixs map[string][]string, maskCount int) []IndexWord {
+// 索引词按 类型+词 唯一，不同类型的索引词可以是同一个词
+func _step3_index_word_key(word_type int, word string) string {
+	return fmt.Sprintf("%d|%s", word_type, word)
+}
+
+// 将索引词加入集合，已存在的索引词合并字典词ID
+func _step3_add_index_word(indexWordSet map[string]IndexWord, word_type int, word string, word_len int, dictId map[int]bool) {
+	key := _step3_index_word_key(word_type, word)
+	iw, exist := indexWordSet[key]
+	if !exist {
+		iw = IndexWord{
+			Type:    word_type,
+			Word:    word,
+			WordLen: word_len,
+		}
+	}
+	iw.Merge(dictId)
+	indexWordSet[key] = iw
+}
+
+// 用于生成拼音的索引词及其在来源短语中的读音
+type _step3_plain_word struct {
+	readings []pinyin_reading
+	dictId   map[int]bool
+}
+
+func _step3_split_dict_word_to_index_words(dictWords []DictWord, dictPrefixs map[string][]string, dictSuffixs map[string][]string, opts *IndexOptions) []IndexWord {
 	charWordIndexSet := make(map[string]IndexWord)
+	plainWordSet := make(map[string]*_step3_plain_word)
 	for _, dw := range dictWords {
 		words := strings.Split(dw.WordChars, "|")
 		sentence := CreateIndexSentence(words)
-		sentence.IndexSentenceTrim(dictPrefixs[dw.Dict], dictSuffixs[dw.Dict], 6)
-		index_words := sentence.SplitToIndexWords(maskCount, true)
+		sentence.IndexSentenceTrim(dictPrefixs[dw.Dict], dictSuffixs[dw.Dict], opts.EndingDigits)
+		index_words := sentence.SplitToIndexWordsWithOptions(opts, true)
 		for _, sub := range index_words {
 			sub = strings.TrimSpace(sub)
 			if len(sub) == 0 {
 				continue
 			}
-			if iw, exist := charWordIndexSet[sub]; !exist {
-				charWordIndexSet[sub] = IndexWord{
-					Type:    0,
-					Word:    sub,
-					WordLen: _step3_calc_index_word_weight(sub),
-					DictId:  map[int]bool{dw.ID: true},
+			_step3_add_index_word(charWordIndexSet, 0, sub, _step3_calc_index_word_weight(sub), map[int]bool{dw.ID: true})
+		}
+
+		// 不带掩码、不乱序的含汉字索引词，用于生成拼音及拼音首字母索引词
+		// 读音按去除高频前缀后缀之前的短语确定，切出的后缀沿用短语中的读音
+		plain_opts := *opts
+		plain_opts.MaskCount = 0
+		for i, p := range sentence.index_phrases {
+			for _, sub := range p.SplitToIndexWords(&plain_opts, false) {
+				sub = strings.TrimSpace(sub)
+				if !HasHanChar(sub) {
+					continue
 				}
-			} else {
-				iw.DictId[dw.ID] = true
-				charWordIndexSet[sub] = iw
+				readings, ok := index_word_pinyin_readings(sub, words[i])
+				if !ok {
+					continue
+				}
+				key := pinyin_readings_key(readings)
+				pw, exist := plainWordSet[key]
+				if !exist {
+					pw = &_step3_plain_word{readings: readings, dictId: make(map[int]bool)}
+					plainWordSet[key] = pw
+				}
+				pw.dictId[dw.ID] = true
 			}
 		}
 	}
 
-	results := make([]IndexWord, 0, len(charWordIndexSet))
-	for _, iw := range charWordIndexSet {
-		results = append(results, iw)
-		if HasHanChar(iw.Word) {
-			py, ok := PinyinOfWord(iw.Word)
-			if !ok {
-				continue
+	indexWordSet := make(map[string]IndexWord, len(charWordIndexSet)+len(plainWordSet)*3)
+	for key, iw := range charWordIndexSet {
+		indexWordSet[key] = iw
+	}
+	for _, pw := range plainWordSet {
+		// 拼音索引词，多音字生成每个读音组合；不同汉字词的拼音可能相同，按拼音合并字典词ID
+		for _, py := range combine_pinyin_readings(pw.readings, opts.PinyinMaxReadings, format_pinyin_readings) {
+			_step3_add_index_word(indexWordSet, 1, py, _step3_calc_index_word_weight(py), pw.dictId)
+			// 模糊拼音索引词，只在归一化后与拼音不同时生成
+			if fuzzy_py := normalize_fuzzy_pinyin(py, opts.FuzzyRules); fuzzy_py != py {
+				_step3_add_index_word(indexWordSet, 3, fuzzy_py, _step3_calc_index_word_weight(fuzzy_py), pw.dictId)
 			}
-			pyWord := IndexWord{
-				Type:    1,
-				Word:    py,
-				WordLen: _step3_calc_index_word_weight(py),
-				DictId:  iw.DictId,
+		}
+
+		// 拼音首字母索引词
+		for _, abbr := range combine_pinyin_readings(pw.readings, opts.PinyinMaxReadings, format_pinyin_initials) {
+			if len(abbr) < pinyinMinInitials {
+				continue
 			}
-			results = append(results, pyWord)
+			_step3_add_index_word(indexWordSet, 2, abbr, len([]rune(abbr)), pw.dictId)
 		}
 	}
 
+	results := make([]IndexWord, 0, len(indexWordSet))
+	for _, iw := range indexWordSet {
+		results = append(results, iw)
+	}
+
 	return results
 }
 
-func _step3_load_prefix_suffix(db *sqlx.DB, minFreq int) (map[string][]string, map[string][]string) {
+func _step3_load_prefix_suffix(db *sqlx.DB, minFreq int) (map[string][]string, map[string][]string, error) {
 	prefixMap := make(map[string][]string)
 	suffixMap := make(map[string][]string)
 
 	var repeatWords []DictWordRepeat
 	err := db.Select(&repeatWords, "SELECT id, dict, type, word, word_len, repeat_count FROM dict_word_repeats WHERE repeat_count >= ? and ((type = 0 and word_len > 3) or type = 1) order by type, dict, word_len desc", minFreq)
 	if err != nil {
-		log.Printf("Query failed: %v", err)
-		return prefixMap, suffixMap
+		return nil, nil, fmt.Errorf("failed to query dict_word_repeats: %w", err)
 	}
 
 	for _, rw := range repeatWords {
@@ -93,25 +144,27 @@ func _step3_load_prefix_suffix(db *sqlx.DB, minFreq int) (map[string][]string, m
 		}
 	}
 
-	return prefixMap, suffixMap
+	return prefixMap, suffixMap, nil
 }
 
 // 读取字典词 dict_words 表；每批次100条，通过通道传递
-func step3_proc_range_read_dict_words(db *sqlx.DB, idrange IDRange, recordCh chan<- []IndexWord, dictPrefixs map[string][]string, dictSuffixs map[string][]string, maskCount int) error {
-	range_batch := 150
-	for i := idrange.MinId; i <= idrange.MaxId; i += range_batch {
+func step3_proc_range_read_dict_words(db *sqlx.DB, idrange IDRange, recordCh chan<- []IndexWord, dictPrefixs map[string][]string, dictSuffixs map[string][]string, b *index_build) error {
+	range_batch := b.opts.ReadBatch
+	for i := idrange.MinId; i <= idrange.MaxId && !b.canceled(); i += range_batch {
 		var records []DictWord
 		err := db.Select(&records, "SELECT id, dict, word_chars FROM dict_words WHERE id >= ? AND id < ? ORDER BY id", i, i+range_batch)
 		if err != nil {
-			log.Printf("Query failed: %v", err)
-			continue
+			return fmt.Errorf("failed to query dict_words: %w", err)
 		}
-		index_records := _step3_split_dict_word_to_index_words(records, dictPrefixs, dictSuffixs, maskCount)
+		index_records := _step3_split_dict_word_to_index_words(records, dictPrefixs, dictSuffixs, b.opts)
+		b.advance(len(records))
 		if len(index_records) == 0 {
 			continue
 		}
 		log.Printf("Read %d dict words from range [%d - %d] and split to %d index words", len(records), i, i+range_batch, len(index_records))
-		recordCh <- index_records
+		if !send_batch(b.ctx, recordCh, index_records) {
+			return nil
+		}
 	}
 	return nil
 }
@@ -164,6 +217,7 @@ func _step3_query_exist_index_dict_relations(tx *sqlx.Tx, indexIds []int) (map[i
 	return indexToDictMap, nil
 }
 
+// 查询已存在的索引词，返回 类型+词 -> 索引词ID
 func _step3_query_index_words(tx *sqlx.Tx, index_words []string) (map[string]int, error) {
 	const batchSize = 800 // 每批次的最大参数数量，根据数据库的限制调整, sqlite现在最大999个参数
 	exists_index_words := make(map[string]int)
@@ -194,14 +248,14 @@ func _step3_query_index_words(tx *sqlx.Tx, index_words []string) (map[string]int
 		}
 
 		for _, iw := range batchResults {
-			exists_index_words[iw.Word] = iw.ID
+			exists_index_words[_step3_index_word_key(iw.Type, iw.Word)] = iw.ID
 		}
 	}
 
 	return exists_index_words, nil
 }
 
-func _step3_insert_index_word(tx *sqlx.Tx, indexWords []IndexWord) int {
+func _step3_insert_index_word(tx *sqlx.Tx, indexWords []IndexWord) (int, error) {
 	const batchSize = 1000
 	count := 0
 
@@ -217,14 +271,13 @@ func _step3_insert_index_word(tx *sqlx.Tx, indexWords []IndexWord) int {
 		// 插入新的索引词
 		stmt, err := tx.Prepare("INSERT INTO index_words (type, word, word_len) VALUES (?, ?, ?)")
 		if err != nil {
-			tx.Rollback()
-			log.Fatalf("准备语句失败: %v", err)
+			return count, fmt.Errorf("failed to prepare index_words insert: %w", err)
 		}
 		for i := range batch {
 			result, err := stmt.Exec(batch[i].Type, batch[i].Word, batch[i].WordLen)
 			if err != nil {
-				tx.Rollback()
-				log.Fatalf("插入记录失败: %v", err)
+				stmt.Close()
+				return count, fmt.Errorf("failed to insert index word %q: %w", batch[i].Word, err)
 			}
 			count++
 			nid, _ := result.LastInsertId()
@@ -233,10 +286,10 @@ func _step3_insert_index_word(tx *sqlx.Tx, indexWords []IndexWord) int {
 		stmt.Close()
 	}
 
-	return count
+	return count, nil
 }
 
-func _step3_insert_index_dict_relation(tx *sqlx.Tx, indexWords []IndexWord) int {
+func _step3_insert_index_dict_relation(tx *sqlx.Tx, indexWords []IndexWord) (int, error) {
 	const batchSize = 1000
 	count := 0
 
@@ -252,8 +305,7 @@ func _step3_insert_index_dict_relation(tx *sqlx.Tx, indexWords []IndexWord) int
 		// 插入新的索引词与字典词的关系
 		stmt, err := tx.Prepare("INSERT INTO dict_index_ids (index_id, dict_id) VALUES (?, ?)")
 		if err != nil {
-			tx.Rollback()
-			log.Fatalf("准备语句失败: %v", err)
+			return count, fmt.Errorf("failed to prepare dict_index_ids insert: %w", err)
 		}
 
 		// 插入新的索引词与字典词的关系
@@ -264,8 +316,8 @@ func _step3_insert_index_dict_relation(tx *sqlx.Tx, indexWords []IndexWord) int
 			for dictId, _ := range indexWord.DictId {
 				_, err := stmt.Exec(indexWord.ID, dictId)
 				if err != nil {
-					tx.Rollback()
-					log.Fatalf("插入记录失败: %v", err)
+					stmt.Close()
+					return count, fmt.Errorf("failed to insert dict_index_ids: %w", err)
 				}
 				count++
 			}
@@ -273,18 +325,21 @@ func _step3_insert_index_dict_relation(tx *sqlx.Tx, indexWords []IndexWord) int
 		stmt.Close()
 	}
 
-	return count
+	return count, nil
 }
 
-func step3_proc_create_index_words(db *sqlx.DB, recordCh <-chan []IndexWord) int {
+// 写入失败时回滚该批次并通过 b.fail 取消创建，之后的批次全部丢弃
+func step3_proc_create_index_words(db *sqlx.DB, b *index_build, recordCh <-chan []IndexWord) int {
 	count := 0
 	for batch := range recordCh {
+		if b.canceled() { // 已取消时丢弃剩余批次，等待读取协程退出后通道关闭
+			continue
+		}
 		tx, err := db.Beginx() // 开启事务
 		if err != nil {
-			log.Fatalf("事务开启失败: %v", err)
+			b.fail(fmt.Errorf("failed to begin transaction: %w", err))
+			continue
 		}
-		insert_count := 0
-		relation_count := 0
 
 		index_words := make([]string, 0, len(batch))
 		for _, rec := range batch {
@@ -295,14 +350,15 @@ func step3_proc_create_index_words(db *sqlx.DB, recordCh <-chan []IndexWord) int
 		exists_index_words, err := _step3_query_index_words(tx, index_words)
 		if err != nil {
 			tx.Rollback()
-			log.Fatalf("查询失败: %v", err)
+			b.fail(fmt.Errorf("failed to query index_words: %w", err))
+			continue
 		}
 
 		exist_index_ids := []int{}
 		update_index_word_set := make(map[int]IndexWord)
 		insert_index_words := []IndexWord{}
 		for _, rec := range batch {
-			if indexId, exist := exists_index_words[rec.Word]; exist {
+			if indexId, exist := exists_index_words[_step3_index_word_key(rec.Type, rec.Word)]; exist {
 				rec.ID = indexId
 				exist_index_ids = append(exist_index_ids, indexId)
 				update_index_word_set[indexId] = rec
@@ -315,7 +371,8 @@ func step3_proc_create_index_words(db *sqlx.DB, recordCh <-chan []IndexWord) int
 		indexToDictMap, err := _step3_query_exist_index_dict_relations(tx, exist_index_ids)
 		if err != nil {
 			tx.Rollback()
-			log.Fatalf("查询失败: %v", err)
+			b.fail(fmt.Errorf("failed to query dict_index_ids: %w", err))
+			continue
 		}
 		for indexId, dictIds := range indexToDictMap {
 			if iw, exist := update_index_word_set[indexId]; exist {
@@ -328,7 +385,12 @@ func step3_proc_create_index_words(db *sqlx.DB, recordCh <-chan []IndexWord) int
 		}
 
 		// 插入新的索引词
-		insert_count = _step3_insert_index_word(tx, insert_index_words)
+		insert_count, err := _step3_insert_index_word(tx, insert_index_words)
+		if err != nil {
+			tx.Rollback()
+			b.fail(err)
+			continue
+		}
 
 		// 插入索引词与字典词的关系
 		for _, indexWord := range update_index_word_set {
@@ -337,41 +399,52 @@ func step3_proc_create_index_words(db *sqlx.DB, recordCh <-chan []IndexWord) int
 			}
 			insert_index_words = append(insert_index_words, indexWord)
 		}
-		relation_count = _step3_insert_index_dict_relation(tx, insert_index_words)
+		relation_count, err := _step3_insert_index_dict_relation(tx, insert_index_words)
+		if err != nil {
+			tx.Rollback()
+			b.fail(err)
+			continue
+		}
 
 		// 提交事务
 		if err := tx.Commit(); err != nil {
 			tx.Rollback()
-			log.Fatalf("事务提交失败: %v", err)
+			b.fail(fmt.Errorf("failed to commit index words: %w", err))
+			continue
 		}
 		log.Printf("插入索引词 %d 条，插入索引词与字典词关系 %d 条", insert_count, relation_count)
 		count += insert_count
+		b.report()
 	}
 	return count
 }
 
-func step3_main_create_index_words(db *sqlx.DB, maskCount int, minFreq int) int {
+func step3_main_create_index_words(db *sqlx.DB, b *index_build) (int, error) {
 	// 创建通道
 	recordCh := make(chan []IndexWord, 100)
 
 	table_range := getTableRange(db, "dict_words", "")
 	if table_range.Count == 0 {
-		return 0
+		return 0, nil
 	}
 
-	dictPrefixs, dictSuffixs := _step3_load_prefix_suffix(db, minFreq)
+	b.set_total(table_range.Count)
+	dictPrefixs, dictSuffixs, err := _step3_load_prefix_suffix(db, b.opts.MinFreq)
+	if err != nil {
+		return 0, err
+	}
 
 	var wg sync.WaitGroup
 
-	worker_ranges := table_range.Split(10000, 0)
+	worker_ranges := table_range.Split(10000, b.opts.Workers)
 	// worker_ranges := []IDRange{table_range}
 	log.Printf("词典共计 %d 条记录，分为 %d 个协程并行读取", table_range.Count, len(worker_ranges))
 	for _, wr := range worker_ranges {
 		wg.Add(1)
 		go func(idrange IDRange) {
 			defer wg.Done()
-			if err := step3_proc_range_read_dict_words(db, idrange, recordCh, dictPrefixs, dictSuffixs, maskCount); err != nil {
-				log.Printf("Error reading dict words: %v\n", err)
+			if err := step3_proc_range_read_dict_words(db, idrange, recordCh, dictPrefixs, dictSuffixs, b); err != nil {
+				b.fail(err)
 			}
 		}(wr)
 	}
@@ -383,5 +456,6 @@ func step3_main_create_index_words(db *sqlx.DB, maskCount int, minFreq int) int
 	}()
 
 	// 用当前主协程处理读取的字典词
-	return step3_proc_create_index_words(db, recordCh)
+	count := step3_proc_create_index_words(db, b, recordCh)
+	return count, b.err()
 }
@@ -1,6 +1,7 @@
 package radix
 
 // 根据索引词 index_words, 创建索引树 str_radix_nodes，根据word_len逐层创建索引节点，不压缩
+// 文字相同的不同类型索引词（如字符索引词与拼音首字母索引词）共用一个节点，节点的 index_id 为先写入的索引词，其余记录在 node_index_ids
 
 import (
 	"fmt"
@@ -39,9 +40,20 @@ func _step4_query_and_merge_radix_node(tx *sqlx.Tx, nodes []StrRadixNode) ([]Str
 	for _, rn := range batchResults {
 		src, exists := batchNodes[rn.HierarchyKey]
 		if exists {
-			if rn.IndexID == 0 && src.IndexID > 0 {
-				rn.IndexID = src.IndexID
-				update_nodes = append(update_nodes, rn)
+			// 节点已有索引词时，文字相同的其他索引词记录到 node_index_ids
+			src_ids := append([]int{src.IndexID}, src.IndexIDs...)
+			for _, index_id := range src_ids {
+				switch {
+				case index_id == 0 || index_id == rn.IndexID:
+				case rn.IndexID == 0:
+					rn.IndexID = index_id
+					update_nodes = append(update_nodes, rn)
+				default:
+					if err := _step4_insert_node_index_id(tx, rn.ID, index_id); err != nil {
+						tx.Rollback()
+						return nil, err
+					}
+				}
 			}
 			delete(batchNodes, rn.HierarchyKey)
 		}
@@ -65,37 +77,58 @@ func _step4_query_and_merge_radix_node(tx *sqlx.Tx, nodes []StrRadixNode) ([]Str
 	return not_exist_nodes, nil
 }
 
+func _step4_insert_node_index_id(tx *sqlx.Tx, node_id int, index_id int) error {
+	_, err := tx.Exec("insert into node_index_ids (node_id, index_id) values (?, ?)", node_id, index_id)
+	if err != nil {
+		log.Printf("插入失败: %v", err)
+	}
+	return err
+}
+
 func _step4_insert_radix_node(tx *sqlx.Tx, nodes []StrRadixNode) error {
 	sql := `insert into str_radix_nodes (parent_id, key, hierarchy_key, index_id, weight, child_count) values (:parent_id, :key, :hierarchy_key, :index_id, :weight, :child_count)`
 	for _, rn := range nodes {
-		_, err := tx.NamedExec(sql, rn)
+		result, err := tx.NamedExec(sql, rn)
 		if err != nil {
 			log.Printf("插入失败: %v", err)
 			tx.Rollback()
 			return err
 		}
+		if len(rn.IndexIDs) == 0 {
+			continue
+		}
+		node_id, _ := result.LastInsertId()
+		for _, index_id := range rn.IndexIDs {
+			if err := _step4_insert_node_index_id(tx, int(node_id), index_id); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
 	}
 	return nil
 }
 
-func _step4_create_or_update_radix_node(db *sqlx.DB, recordCh <-chan []StrRadixNode) int {
+// 写入失败时回滚该批次并通过 b.fail 取消创建，之后的批次全部丢弃
+func _step4_create_or_update_radix_node(db *sqlx.DB, b *index_build, recordCh <-chan []StrRadixNode) int {
 	total := 0
 	for batch := range recordCh {
 		batch_len := len(batch)
-		if batch_len == 0 {
+		if batch_len == 0 || b.canceled() { // 已取消时丢弃剩余批次，等待读取协程退出后通道关闭
 			continue
 		}
 
 		tx, err := db.Beginx() // 开启事务
 		if err != nil {
-			log.Fatalf("事务开启失败: %v", err)
+			b.fail(fmt.Errorf("failed to begin transaction: %w", err))
+			continue
 		}
 
 		// 查询已存在的索引词
 		not_exist_nodes, err := _step4_query_and_merge_radix_node(tx, batch)
 		if err != nil {
 			tx.Rollback()
-			log.Fatalf("查询失败: %v", err)
+			b.fail(fmt.Errorf("failed to merge str_radix_nodes: %w", err))
+			continue
 		}
 		insert_len := len(not_exist_nodes)
 		update_len := len(batch) - len(not_exist_nodes)
@@ -105,17 +138,20 @@ func _step4_create_or_update_radix_node(db *sqlx.DB, recordCh <-chan []StrRadixN
 			err = _step4_insert_radix_node(tx, not_exist_nodes)
 			if err != nil {
 				tx.Rollback()
-				log.Fatalf("插入失败: %v", err)
+				b.fail(fmt.Errorf("failed to insert str_radix_nodes: %w", err))
+				continue
 			}
 		}
 
 		// 提交事务
 		if err := tx.Commit(); err != nil {
 			tx.Rollback()
-			log.Fatalf("事务提交失败: %v", err)
+			b.fail(fmt.Errorf("failed to commit str_radix_nodes: %w", err))
+			continue
 		}
 		log.Printf("插入 %d 条, 更新 %d 条", insert_len, update_len)
 		total += insert_len
+		b.report()
 	}
 	return total
 }
@@ -188,16 +224,17 @@ func _step4_parse_index_word_to_radix_node(iw IndexWord) []StrRadixNode {
 	return nodes
 }
 
-func _step4_read_index_word_to_radix_node(db *sqlx.DB, recordCh chan<- []StrRadixNode, idrange IDRange, word_len int) {
+func _step4_read_index_word_to_radix_node(db *sqlx.DB, b *index_build, recordCh chan<- []StrRadixNode, idrange IDRange, word_len int) {
 	sql := "select id, word, word_len from index_words where word_len = ? and id >= ? and id <= ?"
 	var records []IndexWord
 	err := db.Select(&records, sql, word_len, idrange.MinId, idrange.MaxId)
 	if err != nil {
-		log.Printf("parse index word to radix node failed: %v", err)
+		b.fail(fmt.Errorf("failed to query index_words: %w", err))
 		return
 	}
 	batch := 500
 	rns := make(map[string]StrRadixNode, batch)
+	defer b.advance(len(records))
 	for _, r := range records {
 		for _, rn := range _step4_parse_index_word_to_radix_node(r) {
 			src, exists := rns[rn.HierarchyKey]
@@ -213,7 +250,9 @@ func _step4_read_index_word_to_radix_node(db *sqlx.DB, recordCh chan<- []StrRadi
 			for _, v := range rns {
 				nodes = append(nodes, v)
 			}
-			recordCh <- nodes
+			if !send_batch(b.ctx, recordCh, nodes) {
+				return
+			}
 			rns = make(map[string]StrRadixNode, batch)
 		}
 	}
@@ -222,11 +261,11 @@ func _step4_read_index_word_to_radix_node(db *sqlx.DB, recordCh chan<- []StrRadi
 		for _, v := range rns {
 			nodes = append(nodes, v)
 		}
-		recordCh <- nodes
+		send_batch(b.ctx, recordCh, nodes)
 	}
 }
 
-func _step4_create_radix_node_level(db *sqlx.DB, level int) int {
+func _step4_create_radix_node_level(db *sqlx.DB, level int, b *index_build) int {
 	level_range := getTableRange(db, "index_words", fmt.Sprintf("where word_len = %d", level))
 	if level_range.Count == 0 {
 		return 0
@@ -235,14 +274,14 @@ func _step4_create_radix_node_level(db *sqlx.DB, level int) int {
 	// 创建通道
 	recordCh := make(chan []StrRadixNode, 100)
 
-	ranges := level_range.Split(3000, 0)
+	ranges := level_range.Split(b.opts.NodeBatch, b.opts.Workers)
 	var wg sync.WaitGroup
 
 	for _, r := range ranges {
 		wg.Add(1)
 		go func(r IDRange) {
 			defer wg.Done()
-			_step4_read_index_word_to_radix_node(db, recordCh, r, level)
+			_step4_read_index_word_to_radix_node(db, b, recordCh, r, level)
 		}(r)
 	}
 
@@ -253,28 +292,31 @@ func _step4_create_radix_node_level(db *sqlx.DB, level int) int {
 	}()
 
 	// 用当前协程写数据
-	return _step4_create_or_update_radix_node(db, recordCh)
+	return _step4_create_or_update_radix_node(db, b, recordCh)
 }
 
-func _step4_get_max_word_len_in_index_words(db *sqlx.DB) int {
-	sql := `select max(word_len) from index_words`
+func _step4_get_max_word_len_in_index_words(db *sqlx.DB) (int, error) {
+	sql := `select ifnull(max(word_len), 0) from index_words`
 	var max_word_len int
-	err := db.Get(&max_word_len, sql)
-	if err != nil {
-		log.Printf("get max word len in index_words failed: %v", err)
+	if err := db.Get(&max_word_len, sql); err != nil {
+		return 0, fmt.Errorf("failed to query max word_len of index_words: %w", err)
 	}
-	return max_word_len
+	return max_word_len, nil
 }
 
-func step4_main_create_radix_node(db *sqlx.DB) int {
-	max_len := _step4_get_max_word_len_in_index_words(db)
+func step4_main_create_radix_node(db *sqlx.DB, b *index_build) (int, error) {
+	max_len, err := _step4_get_max_word_len_in_index_words(db)
+	if err != nil {
+		return 0, err
+	}
 	log.Printf("逐层创建[2-%d]索引节点", max_len)
+	b.set_total(getTableRange(db, "index_words", "").Count)
 	total := 0
-	for i := 1; i <= max_len; i++ {
-		count := _step4_create_radix_node_level(db, i)
+	for i := 1; i <= max_len && !b.canceled(); i++ {
+		count := _step4_create_radix_node_level(db, i, b)
 		total += count
 		log.Printf("创建 %d 级索引节点 %d 条", i, count)
 	}
 	log.Printf("共创建 %d 条索引节点", total)
-	return total
+	return total, b.err()
 }
@@ -0,0 +1,121 @@
+package radix
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func test_index_word_id(t *testing.T, db *sqlx.DB, word_type int, word string) int {
+	t.Helper()
+	var id int
+	if err := db.Get(&id, "SELECT id FROM index_words WHERE type = ? AND word = ?", word_type, word); err != nil {
+		t.Fatalf("index word t%d %q: %v", word_type, word, err)
+	}
+	return id
+}
+
+// 每个索引词都须有节点：节点的 index_id 或 node_index_ids
+func assert_index_words_have_nodes(t *testing.T, db *sqlx.DB) {
+	t.Helper()
+	var missing []IndexWord
+	err := db.Select(&missing, `SELECT id, type, word, word_len FROM index_words i
+		WHERE NOT EXISTS (SELECT 1 FROM str_radix_nodes n WHERE n.index_id = i.id)
+		AND NOT EXISTS (SELECT 1 FROM node_index_ids x WHERE x.index_id = i.id)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, iw := range missing {
+		t.Errorf("index word t%d %q has no node", iw.Type, iw.Word)
+	}
+}
+
+// 查询中 word 处匹配到的全部索引词ID
+func match_index_ids(t *testing.T, db *sqlx.DB, word string) []int {
+	t.Helper()
+	root, err := LoadRadixForest(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]int, 0)
+	for _, m := range root.Match(word) {
+		if m.Word == word {
+			ids = append(ids, m.IndexID)
+		}
+	}
+	return ids
+}
+
+func suggest_dict_names(t *testing.T, s *Searcher, prefix string) []string {
+	t.Helper()
+	suggestions, err := s.Suggest(prefix, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0)
+	for _, sg := range suggestions {
+		for _, dw := range sg.DictWords {
+			names = append(names, dw.Name)
+		}
+	}
+	return names
+}
+
+func TestStep4SharedNodeForCharAndInitials(t *testing.T) {
+	index_path := build_test_index(t, map[string][]string{
+		"toys": {"哆啦A梦", "dlam", "宗国", "zong guo"},
+	}, nil)
+	db := open_test_indexdb(t, index_path)
+	assert_index_words_have_nodes(t, db)
+
+	// dlam 既是字典词 dlam 的字符索引词，也是 哆啦A梦 的拼音首字母索引词
+	char_id := test_index_word_id(t, db, 0, "dlam")
+	initials_id := test_index_word_id(t, db, 2, "dlam")
+	ids := match_index_ids(t, db, "dlam")
+	if !slices.Contains(ids, char_id) || !slices.Contains(ids, initials_id) {
+		t.Errorf("Match(dlam) = %v, want both %d and %d", ids, char_id, initials_id)
+	}
+
+	// zong guo 既是字典词 zong guo 的字符索引词，也是 宗国 的拼音索引词
+	char_id = test_index_word_id(t, db, 0, "zong guo")
+	pinyin_id := test_index_word_id(t, db, 1, "zong guo")
+	ids = match_index_ids(t, db, "zong guo")
+	if !slices.Contains(ids, char_id) || !slices.Contains(ids, pinyin_id) {
+		t.Errorf("Match(zong guo) = %v, want both %d and %d", ids, char_id, pinyin_id)
+	}
+
+	s := open_test_searcher(t, index_path)
+	check_suggest := func(source string) {
+		if names := suggest_dict_names(t, s, "dl"); !slices.Contains(names, "哆啦A梦") {
+			t.Errorf("%s: Suggest(dl) = %v, want 哆啦A梦", source, names)
+		}
+		names := suggest_dict_names(t, s, "zong")
+		if !slices.Contains(names, "宗国") || !slices.Contains(names, "zong guo") {
+			t.Errorf("%s: Suggest(zong) = %v, want 宗国 and zong guo", source, names)
+		}
+	}
+	check_suggest("db")
+	if _, err := s.LoadMemoryIndex(); err != nil {
+		t.Fatal(err)
+	}
+	check_suggest("memory")
+}
+
+func TestStep4SharedNodeForPinyinAndFuzzy(t *testing.T) {
+	opts := DefaultIndexOptions()
+	opts.FuzzyRules = FuzzySSh
+	index_path := build_test_index(t, map[string][]string{
+		"words": {"诗人", "私人"},
+	}, opts)
+	db := open_test_indexdb(t, index_path)
+	assert_index_words_have_nodes(t, db)
+
+	// si ren 既是 私人 的拼音索引词，也是 诗人 的模糊拼音索引词
+	pinyin_id := test_index_word_id(t, db, 1, "si ren")
+	fuzzy_id := test_index_word_id(t, db, 3, "si ren")
+	ids := match_index_ids(t, db, "si ren")
+	if !slices.Contains(ids, pinyin_id) || !slices.Contains(ids, fuzzy_id) {
+		t.Errorf("Match(si ren) = %v, want both %d and %d", ids, pinyin_id, fuzzy_id)
+	}
+}
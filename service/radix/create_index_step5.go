@@ -1,7 +1,11 @@
 package radix
 
+// 根据 hierarchy_key 计算 str_radix_nodes 的父子关系，填写 parent_id 及 child_count，之后校验索引树
+
 import (
+	"fmt"
 	"log"
+	"strings"
 	"sync"
 
 	"github.com/jmoiron/sqlx"
@@ -12,21 +16,21 @@ type NodeChild struct {
 	Cids []int
 }
 
-func _step5_get_max_weight(db *sqlx.DB) int {
-	sql := `select max(weight) from str_radix_nodes`
+func _step5_get_max_weight(db *sqlx.DB) (int, error) {
+	sql := `select ifnull(max(weight), 0) from str_radix_nodes`
 	var max_weight int
-	err := db.Get(&max_weight, sql)
-	if err != nil {
-		log.Printf("get max weight in str_radix_nodes failed: %v", err)
+	if err := db.Get(&max_weight, sql); err != nil {
+		return 0, fmt.Errorf("failed to query max weight of str_radix_nodes: %w", err)
 	}
-	return max_weight
+	return max_weight, nil
 }
 
-func _step5_calc_parent_and_child_count(db *sqlx.DB, weight int, recordCh chan<- []NodeChild) {
+// 父节点的 hierarchy_key 为去掉末尾 key 的部分，多音节的索引词还须去掉音节之间的空格，与 radix_parent_hierarchy_key 一致
+func _step5_calc_parent_and_child_count(db *sqlx.DB, b *index_build, weight int, recordCh chan<- []NodeChild) {
 	parent_weight := weight - 1
 	sql := `
 	with c as (
-		select id as cid, SUBSTR(hierarchy_key, 1, LENGTH(hierarchy_key) - LENGTH(key)) AS ckey 
+		select id as cid, RTRIM(SUBSTR(hierarchy_key, 1, LENGTH(hierarchy_key) - LENGTH(key)), ' ') AS ckey 
 		from str_radix_nodes where weight = $1
 	)
 	select id as pid, cid from str_radix_nodes p inner join c on p.hierarchy_key = c.ckey 
@@ -38,7 +42,8 @@ func _step5_calc_parent_and_child_count(db *sqlx.DB, weight int, recordCh chan<-
 	}
 	err := db.Select(&parent_child, sql, weight, parent_weight)
 	if err != nil {
-		log.Printf("get parent and child failed: %v", err)
+		b.fail(fmt.Errorf("failed to query parents of weight %d nodes: %w", weight, err))
+		return
 	}
 	if len(parent_child) == 0 {
 		return
@@ -57,65 +62,91 @@ func _step5_calc_parent_and_child_count(db *sqlx.DB, weight int, recordCh chan<-
 	for pid, cids := range parent_child_map {
 		batch_nodes = append(batch_nodes, NodeChild{Pid: pid, Cids: cids})
 		if len(batch_nodes) == batch_size {
-			recordCh <- batch_nodes
+			if !send_batch(b.ctx, recordCh, batch_nodes) {
+				return
+			}
 			batch_nodes = make([]NodeChild, 0, batch_size)
 		}
 	}
 	if len(batch_nodes) > 0 {
-		recordCh <- batch_nodes
+		send_batch(b.ctx, recordCh, batch_nodes)
 	}
 }
 
-func _step5_update_parent_and_child_count(db *sqlx.DB, recordCh <-chan []NodeChild) {
-	sql_child_count := `update str_radix_nodes set child_count = :cnt where id = :pid`
-	sql_parent_id := `update str_radix_nodes set parent_id = :pid where id = :cid`
+// 写入失败时回滚该批次并通过 b.fail 取消创建，之后的批次全部丢弃
+func _step5_update_parent_and_child_count(db *sqlx.DB, b *index_build, recordCh <-chan []NodeChild) int {
+	count := 0
 	for batch := range recordCh {
 		batch_len := len(batch)
-		if batch_len == 0 {
+		if batch_len == 0 || b.canceled() { // 已取消时丢弃剩余批次，等待读取协程退出后通道关闭
 			continue
 		}
 
 		tx, err := db.Beginx()
 		if err != nil {
-			log.Fatalf("transaction begin failed: %v", err)
+			b.fail(fmt.Errorf("failed to begin transaction: %w", err))
+			continue
+		}
+		if err := _step5_update_batch(tx, batch); err != nil {
+			tx.Rollback()
+			b.fail(err)
+			continue
+		}
+		if err := tx.Commit(); err != nil {
+			b.fail(fmt.Errorf("failed to commit str_radix_nodes: %w", err))
+			continue
 		}
 
 		for _, node := range batch {
-			_, err = tx.NamedExec(sql_child_count, map[string]interface{}{"cnt": len(node.Cids), "pid": node.Pid})
-			if err != nil {
-				log.Printf("update failed: %v", err)
-				tx.Rollback()
-				return
-			}
-			for _, cid := range node.Cids {
-				_, err = tx.NamedExec(sql_parent_id, map[string]interface{}{"pid": node.Pid, "cid": cid})
-				if err != nil {
-					log.Printf("update failed: %v", err)
-					tx.Rollback()
-					return
-				}
-			}
+			count += len(node.Cids)
+			b.advance(len(node.Cids))
 		}
+		b.report()
+		log.Printf("update parent and child count success: %d", batch_len)
+	}
+	return count
+}
 
-		err = tx.Commit()
+func _step5_update_batch(tx *sqlx.Tx, batch []NodeChild) error {
+	sql_child_count := `update str_radix_nodes set child_count = :cnt where id = :pid`
+	sql_parent_id := `update str_radix_nodes set parent_id = :pid where id = :cid`
+	for _, node := range batch {
+		_, err := tx.NamedExec(sql_child_count, map[string]interface{}{"cnt": len(node.Cids), "pid": node.Pid})
 		if err != nil {
-			log.Fatalf("transaction commit failed: %v", err)
+			return fmt.Errorf("failed to update child_count of node %d: %w", node.Pid, err)
+		}
+		for _, cid := range node.Cids {
+			_, err = tx.NamedExec(sql_parent_id, map[string]interface{}{"pid": node.Pid, "cid": cid})
+			if err != nil {
+				return fmt.Errorf("failed to update parent_id of node %d: %w", cid, err)
+			}
 		}
-
-		log.Printf("update parent and child count success: %d", batch_len)
 	}
+	return nil
 }
 
-func step5_main_clac_heirarchy(db *sqlx.DB) {
+/**
+ * 计算全部节点的父子关系，返回设置了父节点的节点数量
+ * 第一层节点的 weight 为2，没有父节点；从 weight 为3的节点开始，逐层按 hierarchy_key 找到上一层的父节点
+ * 执行前清空全部 parent_id 及 child_count，上次执行留下的、已不存在的父子关系不会保留，可以重复执行
+ */
+func step5_main_clac_heirarchy(db *sqlx.DB, b *index_build) (int, error) {
+	if _, err := db.Exec("UPDATE str_radix_nodes SET parent_id = 0, child_count = 0"); err != nil {
+		return 0, fmt.Errorf("failed to reset str_radix_nodes hierarchy: %w", err)
+	}
+	max_weight, err := _step5_get_max_weight(db)
+	if err != nil {
+		return 0, err
+	}
 	recordCh := make(chan []NodeChild, 100)
 
-	max_weight := _step5_get_max_weight(db)
+	b.set_total(getTableRange(db, "str_radix_nodes", "where weight > 2").Count)
 	var wg sync.WaitGroup
-	for i := 2; i < max_weight; i++ {
+	for i := 3; i <= max_weight; i++ {
 		wg.Add(1)
 		go func(weight int) {
 			defer wg.Done()
-			_step5_calc_parent_and_child_count(db, weight, recordCh)
+			_step5_calc_parent_and_child_count(db, b, weight, recordCh)
 		}(i)
 	}
 
@@ -126,5 +157,62 @@ func step5_main_clac_heirarchy(db *sqlx.DB) {
 	}()
 
 	// 用当前协程写数据
-	_step5_update_parent_and_child_count(db, recordCh)
+	count := _step5_update_parent_and_child_count(db, b, recordCh)
+	return count, b.err()
+}
+
+/**
+ * 校验计算父子关系后的索引树，返回发现的问题
+ * 1. weight 大于2的节点都有上一层的父节点
+ * 2. 每个节点的 child_count 等于以其为父节点的节点数量
+ * 3. 节点的 index_id 都存在于 index_words，没有子节点的叶子节点都有 index_id
+ * 4. 拆分后不少于两层的索引词，都被某个节点的 index_id 或 node_index_ids 引用
+ * 每项校验之间检查取消，已取消时不再校验并返回 nil，由调用方按取消处理
+ */
+func step5_main_verify_heirarchy(db *sqlx.DB, b *index_build) error {
+	checks := []struct {
+		name string
+		sql  string
+	}{
+		{"nodes without parent", `
+			select c.id from str_radix_nodes c left join str_radix_nodes p on p.id = c.parent_id
+			where c.weight > 2 and (p.id is null or p.weight != c.weight - 1)`},
+		{"nodes with wrong child_count", `
+			select p.id from str_radix_nodes p
+			left join (select parent_id, count(*) as cnt from str_radix_nodes where parent_id > 0 group by parent_id) c on c.parent_id = p.id
+			where p.child_count != ifnull(c.cnt, 0)`},
+		{"nodes with missing index word", `
+			select n.id from str_radix_nodes n left join index_words i on i.id = n.index_id
+			where n.index_id > 0 and i.id is null`},
+		{"leaf nodes without index word", `
+			select id from str_radix_nodes where child_count = 0 and index_id = 0`},
+		{"index words without node", `
+			select id from index_words
+			where (instr(trim(word), ' ') > 0 or length(trim(word)) > 1)
+			and id not in (select index_id from str_radix_nodes where index_id > 0)
+			and id not in (select index_id from node_index_ids)`},
+	}
+
+	problems := make([]string, 0)
+	for _, check := range checks {
+		if b.canceled() {
+			return nil
+		}
+		var ids []int
+		if err := db.Select(&ids, check.sql); err != nil {
+			return fmt.Errorf("failed to verify str_radix_nodes: %w", err)
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		sample := ids
+		if len(sample) > 10 {
+			sample = sample[:10]
+		}
+		problems = append(problems, fmt.Sprintf("%d %s (e.g. ids %v)", len(ids), check.name, sample))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("str_radix_nodes verification failed: %s", strings.Join(problems, "; "))
+	}
+	return nil
 }
@@ -0,0 +1,97 @@
+package radix
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 索引词的节点被删除后校验失败；只有一层的索引词没有节点，不算问题
+func TestStep5VerifyIndexWordsWithoutNode(t *testing.T) {
+	index_path := build_test_index(t, map[string][]string{
+		"toys": {"哆啦A梦", "dlam"},
+	}, nil)
+	db := open_test_indexdb(t, index_path)
+	if _, err := db.Exec("INSERT INTO index_words (type, word, word_len) VALUES (0, '中', 1)"); err != nil {
+		t.Fatal(err)
+	}
+	b := new_index_build(context.Background(), DefaultIndexOptions(), nil)
+	if err := step5_main_verify_heirarchy(db, b); err != nil {
+		t.Fatal(err)
+	}
+
+	// dlam 的两个索引词共用一个节点，另一个记录在 node_index_ids
+	result, err := db.Exec("DELETE FROM node_index_ids")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		t.Fatal("expected node_index_ids rows for dlam")
+	}
+	err = step5_main_verify_heirarchy(db, b)
+	if err == nil || !strings.Contains(err.Error(), "1 index words without node") {
+		t.Errorf("verify returned %v, want 1 index word without node", err)
+	}
+}
+
+// 查询父节点失败时记录错误并取消创建
+func TestStep5ParentQueryError(t *testing.T) {
+	db := new_test_indexdb(t)
+	if _, err := db.Exec("DROP TABLE str_radix_nodes"); err != nil {
+		t.Fatal(err)
+	}
+	b := new_index_build(context.Background(), DefaultIndexOptions(), nil)
+	recordCh := make(chan []NodeChild, 1)
+	_step5_calc_parent_and_child_count(db, b, 3, recordCh)
+
+	if err := b.err(); err == nil || !strings.Contains(err.Error(), "failed to query parents of weight 3 nodes") {
+		t.Errorf("b.err() = %v, want the parent query error", err)
+	}
+	if !b.canceled() {
+		t.Error("build was not canceled")
+	}
+}
+
+// 重复计算父子关系得到相同的结果，上次留下的错误父节点及子节点数量被清除
+func TestStep5RecalcHeirarchy(t *testing.T) {
+	db := open_test_indexdb(t, build_places_index(t, nil))
+	type node struct {
+		ID         int `db:"id"`
+		ParentID   int `db:"parent_id"`
+		ChildCount int `db:"child_count"`
+	}
+	load := func() []node {
+		t.Helper()
+		var nodes []node
+		if err := db.Select(&nodes, "SELECT id, parent_id, child_count FROM str_radix_nodes ORDER BY id"); err != nil {
+			t.Fatal(err)
+		}
+		return nodes
+	}
+	recalc := func() {
+		t.Helper()
+		b := new_index_build(context.Background(), DefaultIndexOptions(), nil)
+		if _, err := step5_main_clac_heirarchy(db, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := load()
+	recalc()
+	if got := load(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("second step5 changed the nodes:\n got %v\nwant %v", got, want)
+	}
+
+	// 第一层节点没有父节点，叶子节点没有子节点
+	if _, err := db.Exec("UPDATE str_radix_nodes SET parent_id = 999999 WHERE weight = 2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("UPDATE str_radix_nodes SET child_count = 7 WHERE id NOT IN (SELECT parent_id FROM str_radix_nodes)"); err != nil {
+		t.Fatal(err)
+	}
+	recalc()
+	if got := load(); !reflect.DeepEqual(got, want) {
+		t.Errorf("step5 kept stale hierarchy:\n got %v\nwant %v", got, want)
+	}
+}
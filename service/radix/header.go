@@ -1,13 +1,37 @@
 package radix
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"multiple-recall/basic/com"
+	"os"
 	"path/filepath"
 	"time"
+
+	"github.com/jmoiron/sqlx"
 )
 
-func NewIndex(dict_dir string, index_dir string, index_name string, maskCount int, minFreq int) (string, error) {
+/**
+ * 创建索引
+ * @param opts 创建参数，nil 时使用 DefaultIndexOptions
+ */
+func NewIndex(dict_dir string, index_dir string, index_name string, opts *IndexOptions) (string, error) {
+	return NewIndexContext(context.Background(), dict_dir, index_dir, index_name, opts, nil)
+}
+
+/**
+ * 创建索引，ctx 取消时停止全部读取、写入协程并返回 ctx 的错误，已完成的步骤保留，可以用 ResumeIndexContext 继续
+ * @param progress 进度回调，nil 时不发送进度事件
+ */
+func NewIndexContext(ctx context.Context, dict_dir string, index_dir string, index_name string, opts *IndexOptions, progress IndexProgressFn) (string, error) {
+	if opts == nil {
+		opts = DefaultIndexOptions()
+	}
+	if err := opts.Validate(); err != nil {
+		return "", err
+	}
+
 	start_time := time.Now().UnixMilli()
 	com.TouchDir(index_dir)
 	if index_name == "" {
@@ -22,26 +46,211 @@ func NewIndex(dict_dir string, index_dir string, index_name string, maskCount in
 
 	defer db.Close()
 
-	start_time = time.Now().UnixMilli()
-	csv_cnt, dict_cnt := step1_main_collect_dict_words(db, dict_dir)
-	log.Printf(">>>Step1: 共读取 %d 条记录，成功插入 %d 条词条，耗时 %d ms", csv_cnt, dict_cnt, time.Now().UnixMilli()-start_time)
+	// 创建参数全部记录在 index_metas 中，ResumeIndex 据此继续创建
+	abs_dict_dir, err := filepath.Abs(dict_dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve dict dir: %w", err)
+	}
+	if err := save_index_options(db, opts); err != nil {
+		return "", err
+	}
+	if err := write_index_meta(db, metaDictDir, abs_dict_dir); err != nil {
+		return "", err
+	}
+	if err := write_index_meta(db, metaIndexStep, ""); err != nil {
+		return "", err
+	}
 
-	start_time = time.Now().UnixMilli()
-	repeat_count := step2_main_collect_word_repeat_parts(db)
-	log.Printf(">>>Step2: 计算得出 %d 个高频出现的前缀后缀，耗时 %d ms", repeat_count, time.Now().UnixMilli()-start_time)
+	if err := run_index_steps(ctx, db, index_path, "", progress); err != nil {
+		return "", err
+	}
+	return index_path, nil
+}
 
-	start_time = time.Now().UnixMilli()
-	index_count := step3_main_create_index_words(db, maskCount, minFreq)
-	log.Printf(">>>Setp3: 创建索引 %d 条记录，耗时 %d ms", index_count, time.Now().UnixMilli()-start_time)
+/**
+ * 继续创建中断的索引：从 index_metas 中记录的最后完成的步骤之后继续，创建参数读取自 index_metas
+ * 未完成的步骤可能已写入部分数据，重新执行前清空该步骤及之后步骤写入的表
+ * 索引已全部创建完成时直接返回
+ */
+func ResumeIndex(index_path string) (string, error) {
+	return ResumeIndexContext(context.Background(), index_path, nil)
+}
 
-	start_time = time.Now().UnixMilli()
-	node_count := step4_main_create_radix_node(db)
-	log.Printf(">>>Setp4: 创建节点 %d 条记录，耗时 %d ms", node_count, time.Now().UnixMilli()-start_time)
+// ResumeIndexContext 继续创建中断的索引，取消及进度回调与 NewIndexContext 相同
+func ResumeIndexContext(ctx context.Context, index_path string, progress IndexProgressFn) (string, error) {
+	if _, err := os.Stat(index_path); err != nil {
+		return "", fmt.Errorf("database file does not exist: %w", err)
+	}
+	db, err := initialize_indexdb(index_path, false)
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
 
+	done, ok, err := read_index_meta(db, metaIndexStep)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("index %s has no build progress", index_path)
+	}
+	if err := run_index_steps(ctx, db, index_path, done, progress); err != nil {
+		return "", err
+	}
 	return index_path, nil
 }
 
-func DebugIndex(index_path string, maskCount int, minFreq int) (string, error) {
+// 创建索引的步骤，按顺序执行
+type index_step struct {
+	name   string
+	tables []string // 步骤写入的表，执行前清空以丢弃上次中断时的部分写入
+	run    func(db *sqlx.DB, index_path string, b *index_build) error
+}
+
+var index_steps = []index_step{
+	{name: "step1", tables: []string{"dict_words"}, run: func(db *sqlx.DB, index_path string, b *index_build) error {
+		dict_dir, ok, err := read_index_meta(db, metaDictDir)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("index meta %s is missing", metaDictDir)
+		}
+		start_time := time.Now().UnixMilli()
+		csv_cnt, dict_cnt, err := step1_main_collect_dict_words(db, dict_dir, b)
+		if err != nil {
+			return err
+		}
+		log.Printf(">>>Step1: 共读取 %d 条记录，成功插入 %d 条词条，耗时 %d ms", csv_cnt, dict_cnt, time.Now().UnixMilli()-start_time)
+		return nil
+	}},
+	{name: "step2", tables: []string{"dict_word_repeats"}, run: func(db *sqlx.DB, index_path string, b *index_build) error {
+		start_time := time.Now().UnixMilli()
+		repeat_count, err := step2_main_collect_word_repeat_parts(db, b)
+		if err != nil {
+			return err
+		}
+		log.Printf(">>>Step2: 计算得出 %d 个高频出现的前缀后缀，耗时 %d ms", repeat_count, time.Now().UnixMilli()-start_time)
+		return nil
+	}},
+	{name: "step3", tables: []string{"index_words", "dict_index_ids"}, run: func(db *sqlx.DB, index_path string, b *index_build) error {
+		start_time := time.Now().UnixMilli()
+		index_count, err := step3_main_create_index_words(db, b)
+		if err != nil {
+			return err
+		}
+		log.Printf(">>>Setp3: 创建索引 %d 条记录，耗时 %d ms", index_count, time.Now().UnixMilli()-start_time)
+		return nil
+	}},
+	{name: "step4", tables: []string{"str_radix_nodes", "node_index_ids"}, run: func(db *sqlx.DB, index_path string, b *index_build) error {
+		start_time := time.Now().UnixMilli()
+		node_count, err := step4_main_create_radix_node(db, b)
+		if err != nil {
+			return err
+		}
+		log.Printf(">>>Setp4: 创建节点 %d 条记录，耗时 %d ms", node_count, time.Now().UnixMilli()-start_time)
+		return nil
+	}},
+	// 重复执行时按相同的结果覆盖 parent_id 及 child_count，没有需要清空的表
+	{name: "step5", run: func(db *sqlx.DB, index_path string, b *index_build) error {
+		start_time := time.Now().UnixMilli()
+		link_count, err := step5_main_clac_heirarchy(db, b)
+		if err != nil {
+			return err
+		}
+		log.Printf(">>>Setp5: 计算 %d 个节点的父节点，耗时 %d ms", link_count, time.Now().UnixMilli()-start_time)
+		return nil
+	}},
+	{name: "verify", run: func(db *sqlx.DB, index_path string, b *index_build) error {
+		start_time := time.Now().UnixMilli()
+		if err := step5_main_verify_heirarchy(db, b); err != nil {
+			return err
+		}
+		log.Printf(">>>校验索引节点，耗时 %d ms", time.Now().UnixMilli()-start_time)
+		return nil
+	}},
+	{name: "memory", run: func(db *sqlx.DB, index_path string, b *index_build) error {
+		// 先写入临时文件再重命名，重复执行时直接覆盖
+		start_time := time.Now().UnixMilli()
+		mi, _, err := LoadMemoryIndexContext(b.ctx, db)
+		// 取消时由 run_index_steps 返回取消的错误
+		if b.canceled() {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := save_memory_index_file(db, index_path, mi); err != nil {
+			return err
+		}
+		log.Printf(">>>保存内存索引文件 %s，耗时 %d ms", memory_index_path(index_path), time.Now().UnixMilli()-start_time)
+		return nil
+	}},
+}
+
+/**
+ * 从 done 之后的步骤开始执行，done 为空时从第一步开始
+ * 每个步骤完成后在 index_metas 中记录步骤名称；记录的是名称而不是序号，新增步骤后旧索引仍能正确继续
+ */
+func run_index_steps(ctx context.Context, db *sqlx.DB, index_path string, done string, progress IndexProgressFn) error {
+	next := 0
+	if done != "" {
+		next = -1
+		for i, step := range index_steps {
+			if step.name == done {
+				next = i + 1
+				break
+			}
+		}
+		if next < 0 {
+			return fmt.Errorf("unknown index step %q", done)
+		}
+	}
+	if next >= len(index_steps) {
+		log.Printf("索引已创建完成，最后完成的步骤为 %s", done)
+		return nil
+	}
+	if done != "" {
+		log.Printf("从 %s 之后继续创建索引", done)
+	}
+
+	// 未完成的步骤及之后的步骤写入的数据全部丢弃
+	tables := []string{}
+	for _, step := range index_steps[next:] {
+		tables = append(tables, step.tables...)
+	}
+	if err := clear_index_tables(db, tables); err != nil {
+		return err
+	}
+
+	opts, err := load_index_options(db)
+	if err != nil {
+		return err
+	}
+	b := new_index_build(ctx, opts, progress)
+	defer b.cancel()
+	for _, step := range index_steps[next:] {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("index canceled before %s: %w", step.name, err)
+		}
+		b.begin_stage(step.name)
+		if err := step.run(db, index_path, b); err != nil {
+			return fmt.Errorf("failed to run index %s: %w", step.name, err)
+		}
+		// 取消时步骤提前结束，写入的数据不完整，不记录为已完成
+		if err := ctx.Err(); err != nil {
+			log.Printf("创建索引已取消，%s 未完成", step.name)
+			return fmt.Errorf("index canceled during %s: %w", step.name, err)
+		}
+		b.finish_stage()
+		if err := write_index_meta(db, metaIndexStep, step.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func DebugIndex(index_path string) (string, error) {
 	start_time := time.Now().UnixMilli()
 	db, err := initialize_indexdb(index_path, false)
 	if err != nil {
@@ -51,15 +260,29 @@ func DebugIndex(index_path string, maskCount int, minFreq int) (string, error) {
 
 	defer db.Close()
 
+	// 按创建索引时的参数重新执行步骤
+	opts, err := load_index_options(db)
+	if err != nil {
+		return "", err
+	}
+	b := new_index_build(context.Background(), opts, nil)
+
 	start_time = time.Now().UnixMilli()
-	// index_count := step3_main_create_index_words(db, maskCount, minFreq)
+	// index_count, err := step3_main_create_index_words(db, b)
 	// log.Printf(">>>Setp3: 创建索引 %d 条记录，耗时 %d ms", index_count, time.Now().UnixMilli()-start_time)
 
-	// index_count := step4_main_create_radix_node(db)
+	// index_count, err := step4_main_create_radix_node(db, b)
 	// log.Printf(">>>Setp4: 创建平铺节点 %d 条记录，耗时 %d ms", index_count, time.Now().UnixMilli()-start_time)
 
-	step5_main_clac_heirarchy(db)
-	log.Printf(">>>Setp5: 耗时 %d ms", time.Now().UnixMilli()-start_time)
+	link_count, err := step5_main_clac_heirarchy(db, b)
+	if err != nil {
+		return "", err
+	}
+	log.Printf(">>>Setp5: 计算 %d 个节点的父节点，耗时 %d ms", link_count, time.Now().UnixMilli()-start_time)
+
+	if err := step5_main_verify_heirarchy(db, b); err != nil {
+		return "", err
+	}
 
 	return index_path, nil
 }
@@ -0,0 +1,120 @@
+package radix
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 写入协程失败时，继续创建索引返回该错误，失败的步骤不记录为已完成
+func TestResumeIndexReturnsWriteError(t *testing.T) {
+	index_path := build_places_index(t, nil)
+
+	cases := []struct {
+		done    string // 从该步骤之后继续
+		trigger string // 使该步骤写入失败的触发器
+	}{
+		{"step2", "BEFORE INSERT ON index_words"},
+		{"step3", "BEFORE INSERT ON str_radix_nodes"},
+		{"step4", "BEFORE UPDATE OF parent_id ON str_radix_nodes"},
+	}
+	for _, c := range cases {
+		db := open_test_indexdb(t, index_path)
+		if err := write_index_meta(db, metaIndexStep, c.done); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec("CREATE TRIGGER fail_write " + c.trigger + " BEGIN SELECT RAISE(ABORT, 'write refused'); END"); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := ResumeIndex(index_path)
+		if err == nil || !strings.Contains(err.Error(), "write refused") {
+			t.Errorf("resume after %s returned %v, want the write error", c.done, err)
+		}
+		if done, _, _ := read_index_meta(db, metaIndexStep); done != c.done {
+			t.Errorf("resume after %s recorded %q as done", c.done, done)
+		}
+
+		// 去掉触发器后可以继续完成
+		if _, err := db.Exec("DROP TRIGGER fail_write"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ResumeIndex(index_path); err != nil {
+			t.Fatal(err)
+		}
+		if done, _, _ := read_index_meta(db, metaIndexStep); done != index_steps[len(index_steps)-1].name {
+			t.Errorf("resume after dropping the trigger stopped at %q", done)
+		}
+		db.Close()
+	}
+}
+
+// 取消后返回 ctx 的错误，被取消的步骤不记录为已完成，之后可以继续创建
+func TestNewIndexContextCanceled(t *testing.T) {
+	dict_dir := write_test_dicts(t, map[string][]string{"places": testPlaces})
+	done := ""
+	for _, step := range index_steps {
+		ctx, cancel := context.WithCancel(context.Background())
+		index_dir := filepath.Join(t.TempDir(), "index")
+		_, err := NewIndexContext(ctx, dict_dir, index_dir, "test", nil, func(p IndexProgress) {
+			if p.Stage == step.name {
+				cancel()
+			}
+		})
+		cancel()
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("cancel during %s returned %v, want context.Canceled", step.name, err)
+		}
+
+		index_path := filepath.Join(index_dir, "test.bin")
+		db := open_test_indexdb(t, index_path)
+		if got, _, _ := read_index_meta(db, metaIndexStep); got != done {
+			t.Errorf("cancel during %s recorded %q as done, want %q", step.name, got, done)
+		}
+		db.Close()
+
+		if _, err := ResumeIndexContext(context.Background(), index_path, nil); err != nil {
+			t.Fatalf("resume after cancel during %s: %v", step.name, err)
+		}
+		s := open_test_searcher(t, index_path)
+		if _, err := s.LoadMemoryIndex(); err != nil {
+			t.Fatal(err)
+		}
+		if results, err := s.Search("中国银行", 10); err != nil || len(results) == 0 || results[0].Name != "中国银行" {
+			t.Errorf("Search after resuming from %s = %+v, %v", step.name, results, err)
+		}
+		done = step.name
+	}
+}
+
+// 每个步骤依次发送开始、进度及完成事件，完成事件是该步骤的最后一个事件
+func TestIndexProgressEvents(t *testing.T) {
+	var events []IndexProgress
+	_, err := NewIndexContext(context.Background(), write_test_dicts(t, map[string][]string{"places": testPlaces}), t.TempDir(), "test", nil, func(p IndexProgress) {
+		events = append(events, p)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	next := 0
+	for i, e := range events {
+		if i == 0 || events[i-1].Finished {
+			// 步骤的第一个事件为开始事件
+			if next >= len(index_steps) || e.Stage != index_steps[next].name || e.Done != 0 || e.Finished {
+				t.Fatalf("event %d = %+v, want the start of %s", i, e, index_steps[min(next, len(index_steps)-1)].name)
+			}
+			next++
+			continue
+		}
+		prev := events[i-1]
+		if e.Stage != prev.Stage || e.Done < prev.Done {
+			t.Fatalf("event %d = %+v after %+v", i, e, prev)
+		}
+	}
+	if next != len(index_steps) || !events[len(events)-1].Finished {
+		t.Errorf("events ended with %+v after %d of %d steps", events[len(events)-1], next, len(index_steps))
+	}
+}
@@ -0,0 +1,126 @@
+package radix
+
+// 创建索引的参数：切词规则、高频前缀后缀统计及各步骤的批次大小、协程数量
+// 创建索引时整体以 JSON 保存在 index_metas 中，查询及增量更新时读取，保证与创建索引时的切词规则一致
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type IndexOptions struct {
+	MaskCount         int             `json:"mask_count"`          // 掩码数量，0 时不生成掩码索引词
+	MaskWindow        int             `json:"mask_window"`         // 切词除首个char以外，最多对前几个char打码
+	FuzzyRules        FuzzyPinyinRule `json:"fuzzy_rules"`         // 开启的模糊拼音规则，FuzzyPinyinNone 时不生成模糊拼音索引词
+	PinyinMaxReadings int             `json:"pinyin_max_readings"` // 多音字每个索引词最多生成的读音组合数量
+	MinSplitLen       int             `json:"min_split_len"`       // 前缀切词及掩码索引词的最小长度
+	MinPhraseLen      int             `json:"min_phrase_len"`      // 短语不足 MinSplitLen 时，整体作为索引词的最小长度
+	EndingDigits      int             `json:"ending_digits"`       // 以至少该位数的数字结尾的短语只保留结尾的数字，0 时不处理
+	RepeatMinFreq     int             `json:"repeat_min_freq"`     // step2 统计高频前缀后缀的最小出现次数
+	MinFreq           int             `json:"min_freq"`            // step3 去除的高频前缀后缀的最小出现次数
+	ReadBatch         int             `json:"read_batch"`          // step3 每次读取的字典词数量
+	WriteBatch        int             `json:"write_batch"`         // step1、step2 每批次写入的记录数量
+	NodeBatch         int             `json:"node_batch"`          // step4 每个协程最少处理的索引词数量
+	Workers           int             `json:"workers"`             // 并行读取的协程数量，0 时为 CPU 数量的2倍
+}
+
+func DefaultIndexOptions() *IndexOptions {
+	return &IndexOptions{
+		MaskCount:         2,
+		MaskWindow:        6,
+		FuzzyRules:        FuzzyPinyinNone,
+		PinyinMaxReadings: 4,
+		MinSplitLen:       4,
+		MinPhraseLen:      3,
+		EndingDigits:      6,
+		RepeatMinFreq:     10,
+		MinFreq:           100,
+		ReadBatch:         150,
+		WriteBatch:        1000,
+		NodeBatch:         3000,
+		Workers:           0,
+	}
+}
+
+func (o *IndexOptions) Validate() error {
+	switch {
+	case o.MaskCount < 0:
+		return fmt.Errorf("mask_count must not be negative: %d", o.MaskCount)
+	case o.MaskWindow < 1:
+		return fmt.Errorf("mask_window must be positive: %d", o.MaskWindow)
+	case o.MaskCount > o.MaskWindow: // 掩码只打在 MaskWindow 个char内
+		return fmt.Errorf("mask_count %d must not exceed mask_window %d", o.MaskCount, o.MaskWindow)
+	case o.FuzzyRules&^FuzzyPinyinAll != 0:
+		return fmt.Errorf("unknown fuzzy_rules bits: %d", o.FuzzyRules&^FuzzyPinyinAll)
+	case o.PinyinMaxReadings < 1:
+		return fmt.Errorf("pinyin_max_readings must be positive: %d", o.PinyinMaxReadings)
+	case o.MinPhraseLen < 2: // 索引节点的第一层为两个字符，更短的索引词没有节点
+		return fmt.Errorf("min_phrase_len must be at least 2: %d", o.MinPhraseLen)
+	case o.MinSplitLen < o.MinPhraseLen:
+		return fmt.Errorf("min_split_len %d must not be less than min_phrase_len %d", o.MinSplitLen, o.MinPhraseLen)
+	case o.EndingDigits < 0:
+		return fmt.Errorf("ending_digits must not be negative: %d", o.EndingDigits)
+	case o.RepeatMinFreq < 1:
+		return fmt.Errorf("repeat_min_freq must be positive: %d", o.RepeatMinFreq)
+	case o.MinFreq < 1:
+		return fmt.Errorf("min_freq must be positive: %d", o.MinFreq)
+	case o.ReadBatch < 1 || o.WriteBatch < 1 || o.NodeBatch < 1:
+		return fmt.Errorf("batch sizes must be positive: read %d, write %d, node %d", o.ReadBatch, o.WriteBatch, o.NodeBatch)
+	case o.Workers < 0:
+		return fmt.Errorf("workers must not be negative: %d", o.Workers)
+	}
+	return nil
+}
+
+func (o *IndexOptions) ToJSON() (string, error) {
+	data, err := json.Marshal(o)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal index options: %w", err)
+	}
+	return string(data), nil
+}
+
+// ParseIndexOptions 解析 JSON，未设置的字段使用默认值
+func ParseIndexOptions(data []byte) (*IndexOptions, error) {
+	opts := DefaultIndexOptions()
+	if err := json.Unmarshal(data, opts); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal index options: %w", err)
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+func save_index_options(db *sqlx.DB, opts *IndexOptions) error {
+	value, err := opts.ToJSON()
+	if err != nil {
+		return err
+	}
+	return write_index_meta(db, metaIndexOptions, value)
+}
+
+/**
+ * 读取创建索引时的参数
+ * 没有记录参数的旧索引（包括没有 index_metas 表的索引）使用默认参数
+ */
+func load_index_options(db *sqlx.DB) (*IndexOptions, error) {
+	exists, err := has_index_metas(db)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		value, ok, err := read_index_meta(db, metaIndexOptions)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return ParseIndexOptions([]byte(value))
+		}
+	}
+	log.Printf("索引没有记录创建参数，按默认参数处理")
+	return DefaultIndexOptions(), nil
+}
@@ -0,0 +1,134 @@
+package radix
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 参数保存为 JSON 后解析得到相同的参数；JSON 中未设置的字段使用默认值
+func TestIndexOptionsJSONRoundTrip(t *testing.T) {
+	opts := DefaultIndexOptions()
+	opts.MaskCount = 1
+	opts.FuzzyRules = FuzzySSh | FuzzyNL
+	opts.MinFreq = 50
+	opts.Workers = 3
+
+	data, err := opts.ToJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := ParseIndexOptions([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, opts) {
+		t.Errorf("ParseIndexOptions(%s) = %+v, want %+v", data, parsed, opts)
+	}
+
+	parsed, err = ParseIndexOptions([]byte(`{"mask_count": 0}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DefaultIndexOptions()
+	want.MaskCount = 0
+	if !reflect.DeepEqual(parsed, want) {
+		t.Errorf("partial options parsed to %+v, want %+v", parsed, want)
+	}
+
+	if _, err := ParseIndexOptions([]byte(`{"mask_count": "2"}`)); err == nil {
+		t.Error("ParseIndexOptions accepted a string mask_count")
+	}
+}
+
+func TestIndexOptionsValidate(t *testing.T) {
+	if err := DefaultIndexOptions().Validate(); err != nil {
+		t.Fatalf("default options: %v", err)
+	}
+	cases := []struct {
+		name   string
+		modify func(o *IndexOptions)
+		want   string
+	}{
+		{"negative mask count", func(o *IndexOptions) { o.MaskCount = -1 }, "mask_count"},
+		{"zero mask window", func(o *IndexOptions) { o.MaskWindow = 0 }, "mask_window"},
+		{"mask count over window", func(o *IndexOptions) { o.MaskCount = 3; o.MaskWindow = 2 }, "must not exceed mask_window"},
+		{"unknown fuzzy rule", func(o *IndexOptions) { o.FuzzyRules = FuzzyPinyinAll + 1 }, "fuzzy_rules"},
+		{"zero pinyin readings", func(o *IndexOptions) { o.PinyinMaxReadings = 0 }, "pinyin_max_readings"},
+		{"short phrase", func(o *IndexOptions) { o.MinPhraseLen = 1 }, "min_phrase_len"},
+		{"split shorter than phrase", func(o *IndexOptions) { o.MinSplitLen = 2 }, "min_split_len"},
+		{"negative ending digits", func(o *IndexOptions) { o.EndingDigits = -1 }, "ending_digits"},
+		{"zero repeat freq", func(o *IndexOptions) { o.RepeatMinFreq = 0 }, "repeat_min_freq"},
+		{"zero min freq", func(o *IndexOptions) { o.MinFreq = 0 }, "min_freq"},
+		{"zero read batch", func(o *IndexOptions) { o.ReadBatch = 0 }, "batch sizes"},
+		{"zero write batch", func(o *IndexOptions) { o.WriteBatch = 0 }, "batch sizes"},
+		{"zero node batch", func(o *IndexOptions) { o.NodeBatch = 0 }, "batch sizes"},
+		{"negative workers", func(o *IndexOptions) { o.Workers = -1 }, "workers"},
+	}
+	for _, c := range cases {
+		opts := DefaultIndexOptions()
+		c.modify(opts)
+		err := opts.Validate()
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: Validate() = %v, want an error about %s", c.name, err, c.want)
+		}
+		data, _ := opts.ToJSON()
+		if _, err := ParseIndexOptions([]byte(data)); err == nil {
+			t.Errorf("%s: ParseIndexOptions accepted %s", c.name, data)
+		}
+	}
+}
+
+// 没有记录创建参数的旧索引按默认参数打开
+func TestLoadIndexOptionsLegacyIndex(t *testing.T) {
+	index_path := build_places_index(t, nil)
+	exec := func(query string) {
+		t.Helper()
+		db, err := initialize_indexdb(index_path, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		if _, err := db.Exec(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	exec("DELETE FROM index_metas WHERE key = '" + metaIndexOptions + "'")
+	s := open_test_searcher(t, index_path)
+	if !reflect.DeepEqual(s.options, DefaultIndexOptions()) {
+		t.Errorf("searcher options = %+v, want the defaults", s.options)
+	}
+	if results, err := s.Search("中国银行", 10); err != nil || len(results) == 0 {
+		t.Errorf("Search on an index without options = %v, %v", results, err)
+	}
+	w, err := OpenIndexWriter(index_path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	// 只读打开时没有 index_metas 表，可写打开时补建
+	exec("DROP TABLE index_metas")
+	s = open_test_searcher(t, index_path)
+	if !reflect.DeepEqual(s.options, DefaultIndexOptions()) {
+		t.Errorf("searcher options = %+v, want the defaults", s.options)
+	}
+	db := open_test_indexdb(t, index_path)
+	exists, err := has_index_metas(db)
+	if err != nil || !exists {
+		t.Errorf("has_index_metas after a writable open = %v, %v", exists, err)
+	}
+}
+
+// 查询失败与未记录该键分开返回
+func TestReadIndexMetaError(t *testing.T) {
+	db := open_test_indexdb(t, build_places_index(t, nil))
+	if _, ok, err := read_index_meta(db, "missing"); ok || err != nil {
+		t.Errorf("read_index_meta(missing) = %v, %v", ok, err)
+	}
+	db.Close()
+	if _, _, err := read_index_meta(db, metaIndexOptions); err == nil {
+		t.Error("read_index_meta on a closed db returned no error")
+	}
+}
@@ -0,0 +1,117 @@
+package radix
+
+// 创建索引的进度事件及取消
+// 取消后各步骤的读取协程停止读取并放弃发送，写入协程丢弃通道中剩余的批次，等待读取协程全部退出后返回
+// 写入失败时记录第一个错误并以同样的方式取消，步骤返回该错误
+// 被取消或失败的步骤不记录为已完成，之后可以用 ResumeIndex 继续创建
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/**
+ * 创建索引的进度事件，每个步骤开始、每写入一个批次及步骤完成时各发送一次
+ * Done、Total 的单位随步骤不同：step1 为字典文件中的词条，step2 为字典，step3 为字典词，step4 为索引词，step5 为节点
+ */
+type IndexProgress struct {
+	Stage    string        `json:"stage"`    // 步骤名称，与 index_metas 中记录的步骤相同
+	Done     int           `json:"done"`     // 已处理的数量
+	Total    int           `json:"total"`    // 总数量，0 表示未知
+	Elapsed  time.Duration `json:"elapsed"`  // 步骤已耗时
+	Finished bool          `json:"finished"` // 步骤是否已完成
+}
+
+// IndexProgressFn 接收进度事件，在调用 NewIndexContext 的协程中依次调用，不能阻塞太久
+type IndexProgressFn func(p IndexProgress)
+
+// 一次创建索引的运行状态：取消信号、第一个错误、创建参数及当前步骤的进度
+type index_build struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	mu       sync.Mutex
+	failed   error
+	opts     *IndexOptions
+	progress IndexProgressFn
+	stage    string
+	start    time.Time
+	done     atomic.Int64 // 读取协程累加
+	total    atomic.Int64
+}
+
+func new_index_build(ctx context.Context, opts *IndexOptions, progress IndexProgressFn) *index_build {
+	ctx, cancel := context.WithCancel(ctx)
+	return &index_build{ctx: ctx, cancel: cancel, opts: opts, progress: progress}
+}
+
+func (b *index_build) canceled() bool {
+	return b.ctx.Err() != nil
+}
+
+// 记录第一个错误并取消创建，可以在读取协程中调用
+func (b *index_build) fail(err error) {
+	b.mu.Lock()
+	if b.failed == nil {
+		b.failed = err
+	}
+	b.mu.Unlock()
+	b.cancel()
+}
+
+// 通过 fail 记录的第一个错误
+func (b *index_build) err() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.failed
+}
+
+func (b *index_build) begin_stage(stage string) {
+	b.stage = stage
+	b.start = time.Now()
+	b.done.Store(0)
+	b.total.Store(0)
+	b.report()
+}
+
+func (b *index_build) set_total(total int) {
+	b.total.Store(int64(total))
+}
+
+// 可以在读取协程中调用
+func (b *index_build) advance(n int) {
+	b.done.Add(int64(n))
+}
+
+// 只在写入协程（即调用 NewIndexContext 的协程）中调用
+func (b *index_build) report() {
+	b.emit(false)
+}
+
+func (b *index_build) finish_stage() {
+	b.emit(true)
+}
+
+func (b *index_build) emit(finished bool) {
+	if b.progress == nil {
+		return
+	}
+	b.progress(IndexProgress{
+		Stage:    b.stage,
+		Done:     int(b.done.Load()),
+		Total:    int(b.total.Load()),
+		Elapsed:  time.Since(b.start),
+		Finished: finished,
+	})
+}
+
+// 读取协程向写入协程发送批次，已取消时放弃发送并返回 false
+func send_batch[T any](ctx context.Context, ch chan<- T, batch T) bool {
+	select {
+	case ch <- batch:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -6,8 +6,6 @@ import (
 	"sort"
 	"strings"
 	"unicode"
-
-	"github.com/mozillazg/go-pinyin"
 )
 
 type index_char struct {
@@ -100,10 +98,10 @@ func (is *index_phrase) IndexPhraseTrim(prefixes []string, suffixes []string, en
 	is.index_chars = to_index_chars(phrase_str)
 }
 
-func (is *index_phrase) SplitToIndexWords(maskCount int, outOfOrder bool) []string {
+func (is *index_phrase) SplitToIndexWords(opts *IndexOptions, outOfOrder bool) []string {
 
-	if is.Length() < 4 {
-		if _index_chars_length(is.index_chars) < 3 {
+	if is.Length() < opts.MinSplitLen {
+		if _index_chars_length(is.index_chars) < opts.MinPhraseLen {
 			return []string{""}
 		} else {
 			return []string{is.ToString()}
@@ -116,13 +114,9 @@ func (is *index_phrase) SplitToIndexWords(maskCount int, outOfOrder bool) []stri
 	// 1. 自身作为一个索引词
 	split_words[phrase_str] = true
 
-	// 2. 前缀切词，每少一个char，作为一个索引词，直至切出的索引词长度小于4
-	for i := 1; i < len(is.index_chars)-1; i++ {
-		sub_chars := is.index_chars[i:]
-		if _index_chars_length(sub_chars) < 4 {
-			break
-		}
-		split_words[_index_chars_to_str(sub_chars)] = true
+	// 2. 前缀切词，每少一个char，作为一个索引词，直至切出的索引词长度小于 MinSplitLen
+	for _, offset := range is.split_offsets(opts.MinSplitLen)[1:] {
+		split_words[_index_chars_to_str(is.index_chars[offset:])] = true
 	}
 
 	// 3. 乱序索引词，对每个前缀切词，按 Unicode 编码值排序，作为一个乱序索引词
@@ -130,37 +124,17 @@ func (is *index_phrase) SplitToIndexWords(maskCount int, outOfOrder bool) []stri
 	if outOfOrder {
 		chaos_split_words = make(map[string]bool)
 		for split_word, _ := range split_words {
-			// 将字符串转换为 rune 切片
-			runes := []rune(split_word)
-
-			// 按 Unicode 编码值对 rune 切片排序
-			sort.Slice(runes, func(i, j int) bool {
-				return runes[i] < runes[j]
-			})
-
-			// 将排序后的 rune 切片转换回字符串
-			chaos_split_words[strings.TrimSpace(string(runes))] = true
+			chaos_split_words[sort_word_runes(split_word)] = true
 		}
 	}
 
 	// 4. 掩码索引词，对每个前缀切词，除首尾各一个rune以外，中间的rune，按 1~maskOffCode 个掩码打码，作为掩码索引词
 	var mask_split_words map[string]bool
-	if maskCount > 0 {
+	if opts.MaskCount > 0 {
 		mask_split_words = make(map[string]bool)
 		for split_word, _ := range split_words {
-			split_chars := to_index_chars(split_word)
-			n := min(len(split_chars)-1, 6) // 最多对前6个字符打码
-			r := min(maskCount, n-1)
-			mask_indexes := generateCombinations(n, r)
-			for _, mask_index := range mask_indexes {
-				mask_chars := make([]*index_char, len(split_chars))
-				copy(mask_chars, split_chars)
-				for _, idx := range mask_index {
-					mask_chars[idx] = &index_char{CharStr: "*", CharType: split_chars[idx].CharType}
-				}
-				if _index_chars_length(mask_chars) > 3 {
-					mask_split_words[_index_chars_to_str(mask_chars)] = true
-				}
+			for _, mw := range mask_split_word(split_word, opts) {
+				mask_split_words[mw.word] = true
 			}
 		}
 	}
@@ -171,7 +145,7 @@ func (is *index_phrase) SplitToIndexWords(maskCount int, outOfOrder bool) []stri
 			split_words[chaos_split_word] = true
 		}
 	}
-	if maskCount > 0 {
+	if opts.MaskCount > 0 {
 		for mask_split_word, _ := range mask_split_words {
 			split_words[mask_split_word] = true
 		}
@@ -183,6 +157,61 @@ func (is *index_phrase) SplitToIndexWords(maskCount int, outOfOrder bool) []stri
 	return results
 }
 
+// split_offsets 前缀切词的起始位置：自身，以及每少一个char的切词，直至切出的索引词长度小于 minSplitLen
+func (is *index_phrase) split_offsets(minSplitLen int) []int {
+	offsets := []int{0}
+	for i := 1; i < len(is.index_chars)-1; i++ {
+		if _index_chars_length(is.index_chars[i:]) < minSplitLen {
+			break
+		}
+		offsets = append(offsets, i)
+	}
+	return offsets
+}
+
+// 掩码索引词，positions 为被掩码的 char 在切词中的位置
+type mask_index_word struct {
+	word      string
+	positions []int
+}
+
+// mask_split_word 对切词除首个char以外的前 MaskWindow 个char，按 MaskCount 个掩码打码；创建索引和查询时共用，保证掩码方式一致
+func mask_split_word(split_word string, opts *IndexOptions) []mask_index_word {
+	results := make([]mask_index_word, 0)
+	split_chars := to_index_chars(split_word)
+	n := min(len(split_chars)-1, opts.MaskWindow) // 最多对前 MaskWindow 个字符打码
+	r := min(opts.MaskCount, n-1)
+	if r <= 0 {
+		return results
+	}
+	mask_indexes := generateCombinations(n, r)
+	for _, mask_index := range mask_indexes {
+		mask_chars := make([]*index_char, len(split_chars))
+		copy(mask_chars, split_chars)
+		for _, idx := range mask_index {
+			mask_chars[idx] = &index_char{CharStr: "*", CharType: split_chars[idx].CharType}
+		}
+		if _index_chars_length(mask_chars) >= opts.MinSplitLen {
+			results = append(results, mask_index_word{word: _index_chars_to_str(mask_chars), positions: mask_index})
+		}
+	}
+	return results
+}
+
+// sort_word_runes 按 Unicode 编码值对词的 rune 排序，得到乱序索引词
+func sort_word_runes(word string) string {
+	// 将字符串转换为 rune 切片
+	runes := []rune(word)
+
+	// 按 Unicode 编码值对 rune 切片排序
+	sort.Slice(runes, func(i, j int) bool {
+		return runes[i] < runes[j]
+	})
+
+	// 将排序后的 rune 切片转换回字符串
+	return strings.TrimSpace(string(runes))
+}
+
 // generateCombinations 生成从 1 到 n 中取出 r 个数字的所有组合
 func generateCombinations(n int, r int) [][]int {
 	var results [][]int
@@ -262,10 +291,18 @@ func (is *IndexSentence) IndexSentenceTrim(prefixes []string, suffixes []string,
 	}
 }
 
+// SplitToIndexWords 按默认的切词规则切分索引词
 func (is *IndexSentence) SplitToIndexWords(maskCount int, outOfOrder bool) []string {
+	opts := DefaultIndexOptions()
+	opts.MaskCount = maskCount
+	return is.SplitToIndexWordsWithOptions(opts, outOfOrder)
+}
+
+// SplitToIndexWordsWithOptions 按创建索引的参数切分索引词，查询时须使用与创建索引时相同的参数
+func (is *IndexSentence) SplitToIndexWordsWithOptions(opts *IndexOptions, outOfOrder bool) []string {
 	wordSet := make(map[string]bool)
 	for _, p := range is.index_phrases {
-		words := p.SplitToIndexWords(maskCount, outOfOrder)
+		words := p.SplitToIndexWords(opts, outOfOrder)
 		for _, w := range words {
 			wordSet[w] = true
 		}
@@ -445,26 +482,6 @@ func classify_word(word string) int {
 }
 
 func word_to_pinyin(word string) string {
-	// 汉字转拼音
-	args := pinyin.NewArgs()
-	args.Style = pinyin.Normal // 不带声调的拼音
-
-	result := ""
-	pre_space := false
-	for _, r := range word {
-		if unicode.Is(unicode.Han, r) { // 判断是否为汉字
-			pinyinResult := pinyin.Pinyin(string(r), args)
-			if len(pinyinResult) > 0 && len(pinyinResult[0]) > 0 {
-				if !pre_space {
-					result += " "
-				}
-				result += pinyinResult[0][0] + " " // 只取第一个拼音
-				pre_space = true
-			}
-		} else {
-			result += string(r) // 非汉字部分保持原样
-			pre_space = false
-		}
-	}
-	return strings.TrimSpace(result)
+	py, _ := PinyinOfWord(word)
+	return py
 }
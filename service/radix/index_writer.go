@@ -0,0 +1,422 @@
+package radix
+
+// 增量更新索引：新增、修改或删除单个字典词，只重新计算该字典词的索引词及索引节点，无需重新创建整个索引
+// 1. 字典词原有的索引词关系 dict_index_ids 全部删除，按创建索引时相同的规则重新生成索引词并建立关系
+// 2. 新出现的索引词写入 index_words，并逐层创建或关联 str_radix_nodes 节点；节点已属于其他索引词时记录到 node_index_ids
+// 3. 不再有任何字典词的索引词被删除，其节点不再有索引词且没有子节点时逐层向上删除
+// 高频前缀后缀 dict_word_repeats 不随之更新，沿用创建索引时的统计
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type IndexWriter struct {
+	db          *sqlx.DB
+	options     *IndexOptions
+	dictPrefixs map[string][]string
+	dictSuffixs map[string][]string
+	linked      bool // str_radix_nodes 是否已建立父子关系（已执行 step5），已建立时新节点也维护 parent_id 及 child_count
+}
+
+/**
+ * 打开已存在的索引数据库用于增量更新
+ * 切词规则及高频前缀后缀的最小出现次数读取自 index_metas 中创建索引时的参数
+ * 打开时为 dict_index_ids.dict_id 及 node_index_ids.index_id 建立索引（已存在时跳过）
+ */
+func OpenIndexWriter(index_path string) (*IndexWriter, error) {
+	if _, err := os.Stat(index_path); err != nil {
+		return nil, fmt.Errorf("database file does not exist: %w", err)
+	}
+	db, err := initialize_indexdb(index_path, false)
+	if err != nil {
+		return nil, err
+	}
+	// 写操作串行执行，避免多个连接同时写入
+	db.SetMaxOpenConns(1)
+
+	statements := []string{
+		`CREATE INDEX IF NOT EXISTS "idx_dict_index_ids_dict_id" ON "dict_index_ids" ("dict_id" ASC)`,
+		`CREATE INDEX IF NOT EXISTS "idx_node_index_ids_index_id" ON "node_index_ids" ("index_id" ASC)`,
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to execute statement: %v, error: %w", stmt, err)
+		}
+	}
+
+	options, err := load_index_options(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	dict_prefixs, dict_suffixs, err := _step3_load_prefix_suffix(db, options.MinFreq)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	var linked int
+	if err := db.Get(&linked, "SELECT COUNT(*) FROM (SELECT 1 FROM str_radix_nodes WHERE parent_id > 0 LIMIT 1)"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to query str_radix_nodes: %w", err)
+	}
+
+	return &IndexWriter{
+		db:          db,
+		options:     options,
+		dictPrefixs: dict_prefixs,
+		dictSuffixs: dict_suffixs,
+		linked:      linked > 0,
+	}, nil
+}
+
+func (w *IndexWriter) Close() error {
+	return w.db.Close()
+}
+
+/**
+ * 新增或修改字典词
+ * ID 大于0时按 ID 修改（不存在时以该 ID 新增），否则按 字典+名称 查找已存在的字典词，找不到时新增
+ * @return int 字典词ID
+ */
+func (w *IndexWriter) Upsert(dw DictWord) (int, error) {
+	dw.Name = strings.TrimSpace(dw.Name)
+	dw.Data = strings.TrimSpace(dw.Data)
+	if dw.Name == "" {
+		return 0, fmt.Errorf("dict word name is empty")
+	}
+	if dw.Data == "" {
+		dw.Data = "{}"
+	}
+	sentence := NewIndexSentence(dw.Name)
+	dw.WordChars = sentence.ToString()
+	dw.WordPinyin = sentence.ToPinyin()
+
+	tx, err := w.db.Beginx()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if dw.ID == 0 {
+		err := tx.Get(&dw.ID, "SELECT id FROM dict_words WHERE dict = ? AND name = ? ORDER BY id LIMIT 1", dw.Dict, dw.Name)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("failed to query dict word: %w", err)
+		}
+	}
+
+	exists := false
+	if dw.ID > 0 {
+		var count int
+		if err := tx.Get(&count, "SELECT COUNT(*) FROM dict_words WHERE id = ?", dw.ID); err != nil {
+			return 0, fmt.Errorf("failed to query dict word: %w", err)
+		}
+		exists = count > 0
+	}
+
+	old_index_ids := []int{}
+	switch {
+	case exists:
+		if old_index_ids, err = w.remove_dict_relations(tx, dw.ID); err != nil {
+			return 0, err
+		}
+		_, err = tx.Exec("UPDATE dict_words SET dict = ?, name = ?, data = ?, word_chars = ?, word_pinyin = ? WHERE id = ?", dw.Dict, dw.Name, dw.Data, dw.WordChars, dw.WordPinyin, dw.ID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to update dict word: %w", err)
+		}
+	case dw.ID > 0:
+		_, err = tx.Exec("INSERT INTO dict_words (id, dict, name, data, word_chars, word_pinyin) VALUES (?, ?, ?, ?, ?, ?)", dw.ID, dw.Dict, dw.Name, dw.Data, dw.WordChars, dw.WordPinyin)
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert dict word: %w", err)
+		}
+	default:
+		result, err := tx.Exec("INSERT INTO dict_words (dict, name, data, word_chars, word_pinyin) VALUES (?, ?, ?, ?, ?)", dw.Dict, dw.Name, dw.Data, dw.WordChars, dw.WordPinyin)
+		if err != nil {
+			return 0, fmt.Errorf("failed to insert dict word: %w", err)
+		}
+		id, _ := result.LastInsertId()
+		dw.ID = int(id)
+	}
+
+	index_words := _step3_split_dict_word_to_index_words([]DictWord{dw}, w.dictPrefixs, w.dictSuffixs, w.options)
+	new_index_ids, err := w.add_index_words(tx, dw.ID, index_words)
+	if err != nil {
+		return 0, err
+	}
+
+	orphan_ids := make([]int, 0, len(old_index_ids))
+	for _, id := range old_index_ids {
+		if !new_index_ids[id] {
+			orphan_ids = append(orphan_ids, id)
+		}
+	}
+	if err := w.collect_index_words(tx, orphan_ids); err != nil {
+		return 0, err
+	}
+	if err := w.invalidate_memory_index(tx); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return dw.ID, nil
+}
+
+/**
+ * 删除字典词及其索引词关系，回收不再有字典词的索引词及节点
+ * @return bool 字典词是否存在
+ */
+func (w *IndexWriter) Delete(dict_id int) (bool, error) {
+	tx, err := w.db.Beginx()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM dict_words WHERE id = ?", dict_id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete dict word: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return false, nil
+	}
+
+	old_index_ids, err := w.remove_dict_relations(tx, dict_id)
+	if err != nil {
+		return false, err
+	}
+	if err := w.collect_index_words(tx, old_index_ids); err != nil {
+		return false, err
+	}
+	if err := w.invalidate_memory_index(tx); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+// 删除字典词的全部索引词关系，返回原来关联的索引词ID
+func (w *IndexWriter) remove_dict_relations(tx *sqlx.Tx, dict_id int) ([]int, error) {
+	var index_ids []int
+	if err := tx.Select(&index_ids, "SELECT DISTINCT index_id FROM dict_index_ids WHERE dict_id = ?", dict_id); err != nil {
+		return nil, fmt.Errorf("failed to query dict_index_ids: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM dict_index_ids WHERE dict_id = ?", dict_id); err != nil {
+		return nil, fmt.Errorf("failed to delete dict_index_ids: %w", err)
+	}
+	return index_ids, nil
+}
+
+// 写入字典词的索引词及关系，新的索引词同时创建节点；返回关联的索引词ID
+func (w *IndexWriter) add_index_words(tx *sqlx.Tx, dict_id int, index_words []IndexWord) (map[int]bool, error) {
+	index_ids := make(map[int]bool, len(index_words))
+	for _, iw := range index_words {
+		err := tx.Get(&iw.ID, "SELECT id FROM index_words WHERE word = ? AND type = ?", iw.Word, iw.Type)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			result, err := tx.Exec("INSERT INTO index_words (type, word, word_len) VALUES (?, ?, ?)", iw.Type, iw.Word, iw.WordLen)
+			if err != nil {
+				return nil, fmt.Errorf("failed to insert index word: %w", err)
+			}
+			id, _ := result.LastInsertId()
+			iw.ID = int(id)
+			if err := w.link_radix_nodes(tx, iw); err != nil {
+				return nil, err
+			}
+		case err != nil:
+			return nil, fmt.Errorf("failed to query index word: %w", err)
+		}
+
+		if _, err := tx.Exec("INSERT INTO dict_index_ids (index_id, dict_id) VALUES (?, ?)", iw.ID, dict_id); err != nil {
+			return nil, fmt.Errorf("failed to insert dict_index_ids: %w", err)
+		}
+		index_ids[iw.ID] = true
+	}
+	return index_ids, nil
+}
+
+// 索引词按 step4 的规则拆分的层数，不足两层的索引词没有节点
+func index_writer_word_parts(word string) int {
+	word = strings.TrimSpace(word)
+	if parts := strings.Split(word, " "); len(parts) > 1 {
+		return len(parts)
+	}
+	return len([]rune(word))
+}
+
+// 逐层创建或关联索引词的节点，最后一层节点指向该索引词
+func (w *IndexWriter) link_radix_nodes(tx *sqlx.Tx, iw IndexWord) error {
+	if index_writer_word_parts(iw.Word) < 2 {
+		return nil
+	}
+	parent_id := 0
+	for i, rn := range _step4_parse_index_word_to_radix_node(iw) {
+		var existing StrRadixNode
+		err := tx.Get(&existing, "SELECT id, parent_id, key, hierarchy_key, index_id, weight, child_count FROM str_radix_nodes WHERE hierarchy_key = ?", rn.HierarchyKey)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			if w.linked && i > 0 {
+				rn.ParentID = parent_id
+			}
+			result, err := tx.NamedExec(`INSERT INTO str_radix_nodes (parent_id, key, hierarchy_key, index_id, weight, child_count) VALUES (:parent_id, :key, :hierarchy_key, :index_id, :weight, :child_count)`, rn)
+			if err != nil {
+				return fmt.Errorf("failed to insert str_radix_nodes: %w", err)
+			}
+			id, _ := result.LastInsertId()
+			if w.linked && i > 0 {
+				if _, err := tx.Exec("UPDATE str_radix_nodes SET child_count = child_count + 1 WHERE id = ?", parent_id); err != nil {
+					return fmt.Errorf("failed to update str_radix_nodes: %w", err)
+				}
+			}
+			parent_id = int(id)
+		case err != nil:
+			return fmt.Errorf("failed to query str_radix_nodes: %w", err)
+		default:
+			if rn.IndexID > 0 {
+				if existing.IndexID == 0 {
+					_, err = tx.Exec("UPDATE str_radix_nodes SET index_id = ? WHERE id = ?", rn.IndexID, existing.ID)
+				} else if existing.IndexID != rn.IndexID {
+					// 不同类型的索引词可能对应同一个节点，如字符索引词与拼音首字母索引词
+					_, err = tx.Exec("INSERT INTO node_index_ids (node_id, index_id) VALUES (?, ?)", existing.ID, rn.IndexID)
+				}
+				if err != nil {
+					return fmt.Errorf("failed to link index word to str_radix_nodes: %w", err)
+				}
+			}
+			parent_id = existing.ID
+		}
+	}
+	return nil
+}
+
+// 回收不再有字典词的索引词，并解除其节点
+func (w *IndexWriter) collect_index_words(tx *sqlx.Tx, index_ids []int) error {
+	for _, index_id := range index_ids {
+		var count int
+		if err := tx.Get(&count, "SELECT COUNT(*) FROM dict_index_ids WHERE index_id = ?", index_id); err != nil {
+			return fmt.Errorf("failed to query dict_index_ids: %w", err)
+		}
+		if count > 0 {
+			continue
+		}
+
+		var word string
+		err := tx.Get(&word, "SELECT word FROM index_words WHERE id = ?", index_id)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to query index word: %w", err)
+		}
+		if _, err := tx.Exec("DELETE FROM index_words WHERE id = ?", index_id); err != nil {
+			return fmt.Errorf("failed to delete index word: %w", err)
+		}
+		if _, err := tx.Exec("DELETE FROM node_index_ids WHERE index_id = ?", index_id); err != nil {
+			return fmt.Errorf("failed to delete node_index_ids: %w", err)
+		}
+		if err := w.unlink_radix_nodes(tx, index_id, strings.TrimSpace(word)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 索引词最后一层节点的 hierarchy_key 即索引词本身；节点改为指向 node_index_ids 中的其他索引词，没有时删除不再需要的节点
+func (w *IndexWriter) unlink_radix_nodes(tx *sqlx.Tx, index_id int, hierarchy_key string) error {
+	var rn StrRadixNode
+	err := tx.Get(&rn, "SELECT id, parent_id, key, hierarchy_key, index_id, weight, child_count FROM str_radix_nodes WHERE hierarchy_key = ?", hierarchy_key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query str_radix_nodes: %w", err)
+	}
+	if rn.IndexID != index_id {
+		return nil
+	}
+
+	var replacement int
+	err = tx.Get(&replacement, "SELECT index_id FROM node_index_ids WHERE node_id = ? ORDER BY id LIMIT 1", rn.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to query node_index_ids: %w", err)
+	}
+	if replacement > 0 {
+		if _, err := tx.Exec("DELETE FROM node_index_ids WHERE node_id = ? AND index_id = ?", rn.ID, replacement); err != nil {
+			return fmt.Errorf("failed to delete node_index_ids: %w", err)
+		}
+	}
+	if _, err := tx.Exec("UPDATE str_radix_nodes SET index_id = ? WHERE id = ?", replacement, rn.ID); err != nil {
+		return fmt.Errorf("failed to update str_radix_nodes: %w", err)
+	}
+	rn.IndexID = replacement
+	return w.prune_radix_nodes(tx, rn)
+}
+
+// 从节点开始逐层向上删除没有索引词也没有子节点的节点
+func (w *IndexWriter) prune_radix_nodes(tx *sqlx.Tx, rn StrRadixNode) error {
+	for rn.IndexID == 0 {
+		has_child, err := index_writer_has_child(tx, rn)
+		if err != nil || has_child {
+			return err
+		}
+		if _, err := tx.Exec("DELETE FROM str_radix_nodes WHERE id = ?", rn.ID); err != nil {
+			return fmt.Errorf("failed to delete str_radix_nodes: %w", err)
+		}
+		if _, err := tx.Exec("DELETE FROM node_index_ids WHERE node_id = ?", rn.ID); err != nil {
+			return fmt.Errorf("failed to delete node_index_ids: %w", err)
+		}
+		if rn.ParentID > 0 {
+			if _, err := tx.Exec("UPDATE str_radix_nodes SET child_count = MAX(child_count - 1, 0) WHERE id = ?", rn.ParentID); err != nil {
+				return fmt.Errorf("failed to update str_radix_nodes: %w", err)
+			}
+		}
+		if rn.Weight <= 2 {
+			return nil
+		}
+
+		parent_key := radix_parent_hierarchy_key(rn.HierarchyKey, rn.Key)
+		err = tx.Get(&rn, "SELECT id, parent_id, key, hierarchy_key, index_id, weight, child_count FROM str_radix_nodes WHERE hierarchy_key = ?", parent_key)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to query str_radix_nodes: %w", err)
+		}
+	}
+	return nil
+}
+
+// 节点是否还有子节点：下一层中 hierarchy_key 以该节点开头、去掉 key 后等于该节点的节点
+func index_writer_has_child(tx *sqlx.Tx, rn StrRadixNode) (bool, error) {
+	var children []StrRadixNode
+	// 0xff 不会出现在 utf8 编码中，作为前缀范围的上界
+	err := tx.Select(&children, "SELECT id, key, hierarchy_key FROM str_radix_nodes WHERE hierarchy_key > ? AND hierarchy_key < ? AND weight = ?", rn.HierarchyKey, rn.HierarchyKey+"\xff", rn.Weight+1)
+	if err != nil {
+		return false, fmt.Errorf("failed to query str_radix_nodes: %w", err)
+	}
+	for _, c := range children {
+		if radix_parent_hierarchy_key(c.HierarchyKey, c.Key) == rn.HierarchyKey {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// 索引数据库已修改，.mem 内存索引文件不再可用
+func (w *IndexWriter) invalidate_memory_index(tx *sqlx.Tx) error {
+	if _, err := tx.Exec("DELETE FROM index_metas WHERE key = ?", metaMemoryIndex); err != nil {
+		return fmt.Errorf("failed to delete index meta %s: %w", metaMemoryIndex, err)
+	}
+	return nil
+}
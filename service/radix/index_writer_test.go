@@ -0,0 +1,107 @@
+package radix
+
+import (
+	"slices"
+	"testing"
+)
+
+// 新增、修改及删除字典词后，查询结果随之变化，不再使用的索引词被删除
+func TestIndexWriterUpsertAndDelete(t *testing.T) {
+	index_path := build_places_index(t, nil)
+	search_names := func(query string) []string {
+		t.Helper()
+		s := open_test_searcher(t, index_path)
+		results, err := s.Search(query, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return search_result_names(results)
+	}
+
+	w, err := OpenIndexWriter(index_path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	dict_id, err := w.Upsert(DictWord{Dict: "places", Name: "天安门广场"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := search_names("天安门广场"); len(names) == 0 || names[0] != "天安门广场" {
+		t.Errorf("after insert: Search(天安门广场) = %v", names)
+	}
+
+	if _, err := w.Upsert(DictWord{ID: dict_id, Dict: "places", Name: "天坛公园"}); err != nil {
+		t.Fatal(err)
+	}
+	if names := search_names("天安门广场"); slices.Contains(names, "天安门广场") {
+		t.Errorf("after update: Search(天安门广场) = %v", names)
+	}
+	if names := search_names("天坛公园"); len(names) == 0 || names[0] != "天坛公园" {
+		t.Errorf("after update: Search(天坛公园) = %v", names)
+	}
+
+	if ok, err := w.Delete(dict_id); err != nil || !ok {
+		t.Fatalf("Delete = %v, %v", ok, err)
+	}
+	if names := search_names("天坛公园"); slices.Contains(names, "天坛公园") {
+		t.Errorf("after delete: Search(天坛公园) = %v", names)
+	}
+	var count int
+	if err := w.db.Get(&count, "SELECT COUNT(*) FROM index_words WHERE word IN ('天安门广场', '天坛公园')"); err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("%d index words of deleted dict words remain", count)
+	}
+	if names := search_names("中国银行"); len(names) == 0 || names[0] != "中国银行" {
+		t.Errorf("after delete: Search(中国银行) = %v", names)
+	}
+}
+
+// 共用节点的两个索引词，删除其中一个的字典词后，另一个仍能匹配到
+func TestIndexWriterDeleteKeepsSharedNode(t *testing.T) {
+	// dlam 既是字典词 dlam 的字符索引词，也是 哆啦A梦 的拼音首字母索引词；分别删除两者，覆盖节点的 index_id 及 node_index_ids 两种情况
+	cases := []struct {
+		deleted   string
+		kept      string
+		kept_type int
+	}{
+		{"dlam", "哆啦A梦", 2},
+		{"哆啦A梦", "dlam", 0},
+	}
+	for _, c := range cases {
+		index_path := build_test_index(t, map[string][]string{
+			"toys": {"哆啦A梦", "dlam"},
+		}, nil)
+		db := open_test_indexdb(t, index_path)
+		kept_id := test_index_word_id(t, db, c.kept_type, "dlam")
+
+		var dict_id int
+		if err := db.Get(&dict_id, "SELECT id FROM dict_words WHERE name = ?", c.deleted); err != nil {
+			t.Fatal(err)
+		}
+		w, err := OpenIndexWriter(index_path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ok, err := w.Delete(dict_id)
+		w.Close()
+		if err != nil || !ok {
+			t.Fatalf("Delete(%s) = %v, %v", c.deleted, ok, err)
+		}
+
+		assert_index_words_have_nodes(t, db)
+		if ids := match_index_ids(t, db, "dlam"); !slices.Equal(ids, []int{kept_id}) {
+			t.Errorf("after deleting %s: Match(dlam) = %v, want [%d]", c.deleted, ids, kept_id)
+		}
+		s := open_test_searcher(t, index_path)
+		results, err := s.Search("dlam", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if names := search_result_names(results); !slices.Equal(names, []string{c.kept}) {
+			t.Errorf("after deleting %s: Search(dlam) = %v, want [%s]", c.deleted, names, c.kept)
+		}
+	}
+}
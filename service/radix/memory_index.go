@@ -0,0 +1,401 @@
+package radix
+
+// 内存索引：启动时将索引数据库中的 index_words 及 dict_index_ids 读入内存中的 Tree，叶子节点保存字典词ID，查询时不再逐条查询数据库
+// 创建索引时同时将内存索引序列化到索引文件旁的 .mem 文件，启动时顺序读取该文件即可，无需再查询数据库
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 索引词及其对应的字典词ID
+type memory_posting struct {
+	Type   int    `db:"type"`
+	Word   string `db:"word"`
+	DictID uint32 `db:"dict_id"`
+}
+
+type MemoryIndex struct {
+	trees map[int]*Tree[Postings] // 索引词类型 -> 索引树
+}
+
+type MemoryIndexStats struct {
+	Words      int    `json:"words"`       // 索引词数量
+	Postings   int    `json:"postings"`    // 索引词与字典词的关系数量
+	LoadMillis int64  `json:"load_millis"` // 加载耗时
+	HeapBytes  uint64 `json:"heap_bytes"`  // 加载后增加的堆内存
+	Source     string `json:"source"`      // 加载来源：file 为 .mem 文件，db 为索引数据库
+}
+
+// 输入提示使用的索引词类型，及每个节点缓存的字典词最多的索引词数量
+var memorySuggestTypes = []int{0, 1, 2}
+
+const memorySuggestTopK = 50
+
+// 内存索引文件的格式：magic "RDXM" | version(1字节) | stamp | 索引树数量 | 各索引树的类型及 Tree.WriteTo 的内容
+const (
+	memoryIndexMagic   = "RDXM"
+	memoryIndexVersion = 1
+)
+
+// 内存索引文件的路径
+func memory_index_path(index_path string) string {
+	return index_path + ".mem"
+}
+
+/**
+ * 从索引数据库加载内存索引
+ * 按 index_words 的ID范围分成多个协程并行读取，由当前协程写入索引树
+ * @return *MemoryIndex 内存索引
+ * @return *MemoryIndexStats 加载耗时及占用的内存
+ */
+func LoadMemoryIndex(db *sqlx.DB) (*MemoryIndex, *MemoryIndexStats, error) {
+	return LoadMemoryIndexContext(context.Background(), db)
+}
+
+// LoadMemoryIndexContext 从索引数据库加载内存索引，ctx 取消时停止读取协程并返回 ctx 的错误
+func LoadMemoryIndexContext(ctx context.Context, db *sqlx.DB) (*MemoryIndex, *MemoryIndexStats, error) {
+	return _memory_measure_load("db", func(stats *MemoryIndexStats) (*MemoryIndex, error) {
+		return _memory_load_db(ctx, db, stats)
+	})
+}
+
+// 记录加载耗时及堆内存的增量
+func _memory_measure_load(source string, load func(stats *MemoryIndexStats) (*MemoryIndex, error)) (*MemoryIndex, *MemoryIndexStats, error) {
+	start_time := time.Now().UnixMilli()
+	var before runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	stats := &MemoryIndexStats{Source: source}
+	mi, err := load(stats)
+	if err != nil {
+		return nil, nil, err
+	}
+	mi.enable_suggest_top()
+
+	for _, tree := range mi.trees {
+		stats.Words += tree.Len()
+	}
+	var after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	if after.HeapAlloc > before.HeapAlloc {
+		stats.HeapBytes = after.HeapAlloc - before.HeapAlloc
+	}
+	stats.LoadMillis = time.Now().UnixMilli() - start_time
+	log.Printf("加载内存索引(%s)：索引词 %d 个，索引词与字典词关系 %d 条，耗时 %d ms，占用内存 %.2f MB",
+		stats.Source, stats.Words, stats.Postings, stats.LoadMillis, float64(stats.HeapBytes)/1024/1024)
+	return mi, stats, nil
+}
+
+// 从索引数据库读取内存索引
+func _memory_load_db(ctx context.Context, db *sqlx.DB, stats *MemoryIndexStats) (*MemoryIndex, error) {
+	table_range := getTableRange(db, "index_words", "")
+	mi := &MemoryIndex{trees: make(map[int]*Tree[Postings])}
+	if table_range.Count > 0 {
+		// 读取失败时取消其余读取协程
+		read_ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		recordCh := make(chan []memory_posting, 100)
+		errCh := make(chan error, 1)
+
+		var wg sync.WaitGroup
+		for _, wr := range table_range.Split(10000, 0) {
+			wg.Add(1)
+			go func(idrange IDRange) {
+				defer wg.Done()
+				if err := _memory_read_postings(read_ctx, db, idrange, recordCh); err != nil {
+					select {
+					case errCh <- err:
+					default:
+					}
+					cancel()
+				}
+			}(wr)
+		}
+
+		// 等待所有读取协程完成
+		go func() {
+			wg.Wait()
+			close(recordCh)
+		}()
+
+		// 用当前协程写入索引树；同一批次中包含索引词的全部字典词ID，先按索引词归并再插入
+		for batch := range recordCh {
+			// 取消后丢弃剩余的批次
+			if read_ctx.Err() != nil {
+				continue
+			}
+			grouped := make(map[memory_posting]Postings)
+			for _, p := range batch {
+				key := memory_posting{Type: p.Type, Word: p.Word}
+				grouped[key] = grouped[key].Add(p.DictID)
+				stats.Postings++
+			}
+			for key, postings := range grouped {
+				tree, exists := mi.trees[key.Type]
+				if !exists {
+					tree = NewPostingTree()
+					mi.trees[key.Type] = tree
+				}
+				tree.Insert(key.Word, postings)
+			}
+		}
+
+		select {
+		case err := <-errCh:
+			return nil, err
+		default:
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return mi, nil
+}
+
+// 读取ID范围内的索引词及对应的字典词ID；每批次1000个索引词，通过通道传递，取消时停止读取
+func _memory_read_postings(ctx context.Context, db *sqlx.DB, idrange IDRange, recordCh chan<- []memory_posting) error {
+	range_batch := 1000
+	for i := idrange.MinId; i <= idrange.MaxId; i += range_batch {
+		var records []memory_posting
+		err := db.SelectContext(ctx, &records, "SELECT w.type, w.word, d.dict_id FROM index_words w INNER JOIN dict_index_ids d ON d.index_id = w.id WHERE w.id >= ? AND w.id < ? AND w.id <= ?", i, i+range_batch, idrange.MaxId)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read index postings: %w", err)
+		}
+		if len(records) > 0 && !send_batch(ctx, recordCh, records) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// 为输入提示建立各节点的 top-k 缓存，按字典词数量排序，掩码索引词不参与
+func (mi *MemoryIndex) enable_suggest_top() {
+	for _, word_type := range memorySuggestTypes {
+		if tree, exists := mi.trees[word_type]; exists {
+			tree.EnableTopK(memorySuggestTopK, func(key string, v Postings) int {
+				if strings.Contains(key, "*") {
+					return 0
+				}
+				return len(v)
+			})
+		}
+	}
+}
+
+// 前缀下字典词最多的补全候选
+func (mi *MemoryIndex) suggest_candidates(prefix string, limit int) []suggest_candidate {
+	candidates := make([]suggest_candidate, 0)
+	for _, word_type := range memorySuggestTypes {
+		tree, exists := mi.trees[word_type]
+		if !exists {
+			continue
+		}
+		for _, wk := range tree.TopKPrefix(prefix, limit) {
+			dict_ids := make([]int, len(wk.Value))
+			for i, id := range wk.Value {
+				dict_ids[i] = int(id)
+			}
+			candidates = append(candidates, suggest_candidate{
+				Word:    wk.Key,
+				Type:    word_type,
+				Weight:  index_word_len(word_type, wk.Key),
+				DictIDs: dict_ids,
+			})
+		}
+	}
+	return candidates
+}
+
+// 查询索引词对应的字典词ID
+func (mi *MemoryIndex) Get(word_type int, word string) (Postings, bool) {
+	tree, exists := mi.trees[word_type]
+	if !exists {
+		return nil, false
+	}
+	return tree.Get(word)
+}
+
+// 指定类型的索引树，不存在时返回 nil
+func (mi *MemoryIndex) Tree(word_type int) *Tree[Postings] {
+	return mi.trees[word_type]
+}
+
+/**
+ * 将内存索引写入 w，各索引树按类型从小到大依次写出
+ * @param stamp 写入文件头的标记，读取时用于确认文件与索引数据库一致
+ */
+func (mi *MemoryIndex) write_to(w io.Writer, stamp uint64) error {
+	bw := bufio.NewWriter(w)
+	types := make([]int, 0, len(mi.trees))
+	for word_type := range mi.trees {
+		types = append(types, word_type)
+	}
+	sort.Ints(types)
+
+	header := []byte(memoryIndexMagic)
+	header = append(header, memoryIndexVersion)
+	header = binary.AppendUvarint(header, stamp)
+	header = binary.AppendUvarint(header, uint64(len(types)))
+	if _, err := bw.Write(header); err != nil {
+		return err
+	}
+	for _, word_type := range types {
+		if _, err := bw.Write(binary.AppendUvarint(nil, uint64(word_type))); err != nil {
+			return err
+		}
+		if _, err := mi.trees[word_type].WriteTo(bw, PostingsCodec); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// 读取 write_to 写出的内存索引，返回文件头中的标记
+func read_memory_index(r io.Reader, stats *MemoryIndexStats) (*MemoryIndex, uint64, error) {
+	br := bufio.NewReaderSize(r, 1<<20)
+	header := make([]byte, len(memoryIndexMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, 0, fmt.Errorf("failed to read memory index header: %w", err)
+	}
+	if string(header[:len(memoryIndexMagic)]) != memoryIndexMagic || header[len(memoryIndexMagic)] != memoryIndexVersion {
+		return nil, 0, fmt.Errorf("unsupported memory index file")
+	}
+	stamp, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read memory index stamp: %w", err)
+	}
+	count, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read memory index tree count: %w", err)
+	}
+
+	mi := &MemoryIndex{trees: make(map[int]*Tree[Postings])}
+	for i := uint64(0); i < count; i++ {
+		word_type, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read memory index tree type: %w", err)
+		}
+		tree, err := ReadTree(br, PostingsCodec, MergePostings)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read memory index tree %d: %w", word_type, err)
+		}
+		tree.Walk(func(k string, v Postings) bool {
+			stats.Postings += len(v)
+			return false
+		})
+		mi.trees[int(word_type)] = tree
+	}
+	return mi, stamp, nil
+}
+
+/**
+ * 从索引数据库读取内存索引，写入索引文件旁的 .mem 文件，并在 index_metas 中记录文件标记
+ * 索引数据库之后若有修改，须删除该标记，使 .mem 文件失效
+ * @param index_path 索引数据库路径
+ */
+func SaveMemoryIndex(db *sqlx.DB, index_path string) (*MemoryIndexStats, error) {
+	mi, stats, err := LoadMemoryIndex(db)
+	if err != nil {
+		return nil, err
+	}
+	if err := save_memory_index_file(db, index_path, mi); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// 内存索引写入临时文件后重命名为 .mem 文件，并在 index_metas 中记录文件标记
+func save_memory_index_file(db *sqlx.DB, index_path string, mi *MemoryIndex) error {
+	stamp := uint64(time.Now().UnixNano())
+	mem_path := memory_index_path(index_path)
+	tmp_path := mem_path + ".tmp"
+	file, err := os.Create(tmp_path)
+	if err != nil {
+		return fmt.Errorf("failed to create memory index file: %w", err)
+	}
+	if err := mi.write_to(file, stamp); err != nil {
+		file.Close()
+		os.Remove(tmp_path)
+		return fmt.Errorf("failed to write memory index file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp_path)
+		return fmt.Errorf("failed to close memory index file: %w", err)
+	}
+	if err := os.Rename(tmp_path, mem_path); err != nil {
+		return fmt.Errorf("failed to rename memory index file: %w", err)
+	}
+	return write_index_meta(db, metaMemoryIndex, strconv.FormatUint(stamp, 10))
+}
+
+/**
+ * 读取索引文件旁的 .mem 文件
+ * 文件不存在，或文件标记与 index_metas 中记录的不一致（索引数据库在写入文件之后有修改）时返回错误
+ */
+func LoadMemoryIndexFile(db *sqlx.DB, index_path string) (*MemoryIndex, *MemoryIndexStats, error) {
+	value, ok, err := read_index_meta(db, metaMemoryIndex)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, fmt.Errorf("memory index file is not recorded in index metas")
+	}
+	expected, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid memory index stamp %q: %w", value, err)
+	}
+
+	return _memory_measure_load("file", func(stats *MemoryIndexStats) (*MemoryIndex, error) {
+		file, err := os.Open(memory_index_path(index_path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open memory index file: %w", err)
+		}
+		defer file.Close()
+
+		mi, stamp, err := read_memory_index(file, stats)
+		if err != nil {
+			return nil, err
+		}
+		if stamp != expected {
+			return nil, fmt.Errorf("memory index file is stale")
+		}
+		return mi, nil
+	})
+}
+
+/**
+ * 加载内存索引，加载完成后查询时的索引词召回直接使用内存索引
+ * 优先读取 .mem 文件，文件不可用时从索引数据库加载
+ * 可以在查询过程中调用（如重新加载），已开始的查询继续使用原来的内存索引
+ */
+func (s *Searcher) LoadMemoryIndex() (*MemoryIndexStats, error) {
+	mi, stats, err := LoadMemoryIndexFile(s.db, s.indexPath)
+	if err != nil {
+		log.Printf("读取内存索引文件失败，改为从索引数据库加载: %v", err)
+		mi, stats, err = LoadMemoryIndex(s.db)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.memory.Store(mi)
+	return stats, nil
+}
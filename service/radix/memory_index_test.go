@@ -0,0 +1,100 @@
+package radix
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// 查询过程中重新加载内存索引，查询结果与只使用数据库时相同；配合 go test -race 检查数据竞争
+func TestLoadMemoryIndexDuringSearch(t *testing.T) {
+	index_path := build_places_index(t, nil)
+	s := open_test_searcher(t, index_path)
+
+	queries := []string{"中国银行", "zhong guo yin hang", "银行", "zgyh"}
+	expected := make(map[string][]string, len(queries))
+	for _, q := range queries {
+		results, err := s.Search(q, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected[q] = search_result_names(results)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				q := queries[j%len(queries)]
+				results, err := s.Search(q, 10)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if names := search_result_names(results); !reflect.DeepEqual(names, expected[q]) {
+					t.Errorf("Search(%s) = %v, want %v", q, names, expected[q])
+				}
+				if _, err := s.Suggest("zhong", 5); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := s.LoadMemoryIndex(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wg.Wait()
+}
+
+// 创建索引时写出的 .mem 文件与从数据库加载的内存索引相同；文件损坏时拒绝读取
+func TestMemoryIndexFile(t *testing.T) {
+	index_path := build_places_index(t, nil)
+	db := open_test_indexdb(t, index_path)
+
+	from_db, _, err := LoadMemoryIndex(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	from_file, stats, err := LoadMemoryIndexFile(db, index_path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Source != "file" || len(from_file.trees) != len(from_db.trees) {
+		t.Fatalf("loaded %d trees from %s, want %d from file", len(from_file.trees), stats.Source, len(from_db.trees))
+	}
+	for word_type, tree := range from_db.trees {
+		if !reflect.DeepEqual(from_file.Tree(word_type).ToMap(), tree.ToMap()) {
+			t.Errorf("tree %d differs between .mem file and database", word_type)
+		}
+	}
+
+	mem_path := memory_index_path(index_path)
+	data, err := os.ReadFile(mem_path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)/2] ^= 0x01
+	if err := os.WriteFile(mem_path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := LoadMemoryIndexFile(db, index_path); err == nil {
+		t.Error("LoadMemoryIndexFile accepted a corrupt file")
+	}
+}
+
+func TestLoadMemoryIndexContextCanceled(t *testing.T) {
+	db := open_test_indexdb(t, build_places_index(t, nil))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := LoadMemoryIndexContext(ctx, db); !errors.Is(err, context.Canceled) {
+		t.Errorf("LoadMemoryIndexContext with a canceled ctx returned %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package radix
+
+// 模糊拼音：按等价规则将拼音归一化，如 zh -> z、l -> n、ing -> in，创建索引时生成 Type 3 模糊拼音索引词，查询时归一化后召回
+
+import (
+	"strings"
+)
+
+// 模糊拼音规则，按位组合，每条规则可以单独开启
+type FuzzyPinyinRule uint32
+
+const (
+	FuzzyZZh   FuzzyPinyinRule = 1 << iota // z = zh
+	FuzzyCCh                               // c = ch
+	FuzzySSh                               // s = sh
+	FuzzyNL                                // n = l
+	FuzzyInIng                             // in = ing
+	FuzzyAnAng                             // an = ang
+	FuzzyFH                                // f = h
+
+	FuzzyPinyinNone FuzzyPinyinRule = 0
+	FuzzyPinyinAll                  = FuzzyZZh | FuzzyCCh | FuzzySSh | FuzzyNL | FuzzyInIng | FuzzyAnAng | FuzzyFH
+)
+
+// 声母规则：将 from 开头的音节替换为 to 开头
+var fuzzyInitialRules = []struct {
+	rule     FuzzyPinyinRule
+	from, to string
+}{
+	{FuzzyZZh, "zh", "z"},
+	{FuzzyCCh, "ch", "c"},
+	{FuzzySSh, "sh", "s"},
+	{FuzzyNL, "l", "n"},
+	{FuzzyFH, "h", "f"},
+}
+
+// 韵母规则：将 from 结尾的音节替换为 to 结尾
+var fuzzyFinalRules = []struct {
+	rule     FuzzyPinyinRule
+	from, to string
+}{
+	{FuzzyInIng, "ing", "in"},
+	{FuzzyAnAng, "ang", "an"},
+}
+
+func (r FuzzyPinyinRule) Has(rule FuzzyPinyinRule) bool {
+	return r&rule != 0
+}
+
+func (r FuzzyPinyinRule) String() string {
+	names := make([]string, 0)
+	for _, ir := range fuzzyInitialRules {
+		if r.Has(ir.rule) {
+			names = append(names, ir.to+"="+ir.from)
+		}
+	}
+	for _, fr := range fuzzyFinalRules {
+		if r.Has(fr.rule) {
+			names = append(names, fr.to+"="+fr.from)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ",")
+}
+
+// 按模糊规则归一化单个音节，非拼音音节保持原样
+func normalize_fuzzy_syllable(syllable string, rules FuzzyPinyinRule) string {
+	if rules == FuzzyPinyinNone || !IsPinyinSyllable(syllable) {
+		return syllable
+	}
+	for _, ir := range fuzzyInitialRules {
+		if rules.Has(ir.rule) && strings.HasPrefix(syllable, ir.from) {
+			syllable = ir.to + strings.TrimPrefix(syllable, ir.from)
+			break
+		}
+	}
+	for _, fr := range fuzzyFinalRules {
+		if rules.Has(fr.rule) && strings.HasSuffix(syllable, fr.from) {
+			syllable = strings.TrimSuffix(syllable, fr.from) + fr.to
+			break
+		}
+	}
+	return syllable
+}
+
+/**
+ * 按模糊规则归一化拼音，如 "zhong guo" -> "zong guo"；创建索引和查询时共用，保证归一化方式一致
+ * @param py 以空格分隔音节的拼音
+ * @param rules 开启的模糊规则
+ */
+func normalize_fuzzy_pinyin(py string, rules FuzzyPinyinRule) string {
+	if rules == FuzzyPinyinNone {
+		return py
+	}
+	syllables := strings.Split(py, " ")
+	for i, syllable := range syllables {
+		syllables[i] = normalize_fuzzy_syllable(syllable, rules)
+	}
+	return strings.Join(syllables, " ")
+}
+
+/**
+ * 模糊拼音召回：查询拼音归一化后，召回 Type 1 拼音索引词和 Type 3 模糊拼音索引词
+ * 归一化后不变的拼音只存在于 Type 1 中，所以两种类型都需要查询
+ */
+func recall_route_fuzzy_pinyin(s *searcher_view, sentence *IndexSentence) (map[int]*RouteHit, error) {
+	if s.options.FuzzyRules == FuzzyPinyinNone {
+		return map[int]*RouteHit{}, nil
+	}
+
+	pys, err := analyze_pinyin_query(s, sentence)
+	if err != nil {
+		return nil, err
+	}
+	words := make(map[string]float64)
+	for _, w := range pys {
+		words[normalize_fuzzy_pinyin(w, s.options.FuzzyRules)] = 1
+	}
+
+	hits, err := search_recall_index_words(s, words, 1)
+	if err != nil {
+		return nil, err
+	}
+	fuzzy_hits, err := search_recall_index_words(s, words, 3)
+	if err != nil {
+		return nil, err
+	}
+	// 同一个字典词在两种类型中命中同一个归一化拼音时，得分只取较高者
+	for dict_id, fh := range fuzzy_hits {
+		hit, exists := hits[dict_id]
+		if !exists {
+			hits[dict_id] = fh
+			continue
+		}
+		hit.Score = max(hit.Score, fh.Score)
+		hit.Matches = append(hit.Matches, fh.Matches...)
+	}
+	return hits, nil
+}
@@ -0,0 +1,287 @@
+package radix
+
+// 多音字：汉字的每个读音都生成拼音，读音组合的数量有上限；常用词通过短语读音表固定读音，如 重庆 -> chong qing
+// 命中短语读音表的汉字串中，其余汉字只取首选读音，如 重庆市 只生成 chong qing shi
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mozillazg/go-pinyin"
+)
+
+// 短语读音表中短语的最大长度
+const pinyinPhraseMaxLen = 4
+
+// 常用多音字短语的读音，命中的汉字只使用表中的读音
+var pinyinPhraseOverrides = map[string][]string{
+	"重庆":  {"chong", "qing"},
+	"重复":  {"chong", "fu"},
+	"重新":  {"chong", "xin"},
+	"重量":  {"zhong", "liang"},
+	"长沙":  {"chang", "sha"},
+	"长春":  {"chang", "chun"},
+	"长城":  {"chang", "cheng"},
+	"长江":  {"chang", "jiang"},
+	"长度":  {"chang", "du"},
+	"成长":  {"cheng", "zhang"},
+	"校长":  {"xiao", "zhang"},
+	"行长":  {"hang", "zhang"},
+	"银行":  {"yin", "hang"},
+	"行业":  {"hang", "ye"},
+	"行情":  {"hang", "qing"},
+	"自行车": {"zi", "xing", "che"},
+	"音乐":  {"yin", "yue"},
+	"乐器":  {"yue", "qi"},
+	"快乐":  {"kuai", "le"},
+	"乐山":  {"le", "shan"},
+	"厦门":  {"xia", "men"},
+	"大厦":  {"da", "sha"},
+	"蚌埠":  {"beng", "bu"},
+	"六安":  {"lu", "an"},
+	"东莞":  {"dong", "guan"},
+	"朝阳":  {"chao", "yang"},
+	"朝鲜":  {"chao", "xian"},
+	"会计":  {"kuai", "ji"},
+	"还是":  {"hai", "shi"},
+	"还款":  {"huan", "kuan"},
+	"便宜":  {"pian", "yi"},
+	"首都":  {"shou", "du"},
+	"都市":  {"du", "shi"},
+	"了解":  {"liao", "jie"},
+	"薄荷":  {"bo", "he"},
+	"角色":  {"jue", "se"},
+	"调查":  {"diao", "cha"},
+	"空调":  {"kong", "tiao"},
+	"曾经":  {"ceng", "jing"},
+	"处理":  {"chu", "li"},
+}
+
+// 词中每个 rune 的可选读音，汉字为一个或多个拼音，非汉字为其自身
+type pinyin_reading struct {
+	options []string
+	han     bool
+}
+
+// 汉字的全部不带声调的读音，去除声调后相同的读音只保留一个，第一个为最常用的读音
+func han_char_readings(r rune) []string {
+	args := pinyin.NewArgs()
+	args.Style = pinyin.Normal
+	args.Heteronym = true
+
+	pinyinResult := pinyin.Pinyin(string(r), args)
+	if len(pinyinResult) == 0 {
+		return nil
+	}
+	readings := make([]string, 0, len(pinyinResult[0]))
+	seen := make(map[string]bool)
+	for _, py := range pinyinResult[0] {
+		if len(py) == 0 || seen[py] {
+			continue
+		}
+		seen[py] = true
+		readings = append(readings, py)
+	}
+	return readings
+}
+
+/**
+ * 分析词中每个 rune 的可选读音，命中短语读音表的汉字只使用表中的读音
+ * 连续的汉字中有命中短语读音表的，其余汉字只使用首选读音
+ * @return []pinyin_reading 每个 rune 的可选读音
+ * @return ok 所有汉字是否都有读音
+ */
+func word_pinyin_readings(word string) ([]pinyin_reading, bool) {
+	runes := []rune(word)
+	readings := make([]pinyin_reading, 0, len(runes))
+	ok := true
+	for i := 0; i < len(runes); {
+		if !unicode.Is(unicode.Han, runes[i]) {
+			readings = append(readings, pinyin_reading{options: []string{string(runes[i])}})
+			i++
+			continue
+		}
+		end := i + 1
+		for end < len(runes) && unicode.Is(unicode.Han, runes[end]) {
+			end++
+		}
+		run, run_ok := han_run_pinyin_readings(runes[i:end])
+		readings = append(readings, run...)
+		ok = ok && run_ok
+		i = end
+	}
+	return readings, ok
+}
+
+// 连续汉字的可选读音，没有读音的汉字不生成读音
+func han_run_pinyin_readings(runes []rune) ([]pinyin_reading, bool) {
+	readings := make([]pinyin_reading, 0, len(runes))
+	overridden := false
+	ok := true
+	for i := 0; i < len(runes); {
+		// 优先匹配最长的短语
+		matched := 0
+		for l := min(pinyinPhraseMaxLen, len(runes)-i); l >= 2; l-- {
+			if syllables, exists := pinyinPhraseOverrides[string(runes[i:i+l])]; exists {
+				for _, py := range syllables {
+					readings = append(readings, pinyin_reading{options: []string{py}, han: true})
+				}
+				matched = l
+				break
+			}
+		}
+		if matched > 0 {
+			overridden = true
+			i += matched
+			continue
+		}
+
+		options := han_char_readings(runes[i])
+		if len(options) == 0 {
+			ok = false
+		} else {
+			readings = append(readings, pinyin_reading{options: options, han: true})
+		}
+		i++
+	}
+
+	if overridden {
+		for i := range readings {
+			readings[i].options = readings[i].options[:1]
+		}
+	}
+	return readings, ok
+}
+
+/**
+ * 索引词在来源短语中的可选读音，索引词为来源短语的后缀等子串时，沿用来源短语中确定的读音
+ * 如 银行大厦 的切词 行大厦 读作 hang da sha，而不是 xing da sha
+ * @param source 切出索引词的短语，索引词不在短语中时按索引词本身分析读音
+ */
+func index_word_pinyin_readings(word string, source string) ([]pinyin_reading, bool) {
+	source_readings, ok := word_pinyin_readings(source)
+	offset := strings.Index(source, word)
+	if !ok || offset < 0 {
+		return word_pinyin_readings(word)
+	}
+	start := utf8.RuneCountInString(source[:offset])
+	return source_readings[start : start+utf8.RuneCountInString(word)], true
+}
+
+// 读音的唯一标识，读音相同的同一个索引词只需要生成一次拼音
+func pinyin_readings_key(readings []pinyin_reading) string {
+	var builder strings.Builder
+	for _, reading := range readings {
+		builder.WriteString(strings.Join(reading.options, "/"))
+		builder.WriteByte(' ')
+	}
+	return builder.String()
+}
+
+/**
+ * 组合每个 rune 的读音，使用非首选读音越少的组合越靠前，第一个组合全部为首选读音
+ * @param format 将选定的读音转换为字符串
+ * @param limit 组合的最大数量
+ */
+func combine_pinyin_readings(readings []pinyin_reading, limit int, format func(readings []pinyin_reading, choices []int) string) []string {
+	type combination struct {
+		choices []int
+		alts    int
+	}
+	combinations := []combination{{choices: []int{}}}
+	for _, reading := range readings {
+		next := make([]combination, 0, len(combinations)*len(reading.options))
+		for _, c := range combinations {
+			for j := range reading.options {
+				choices := append(append(make([]int, 0, len(c.choices)+1), c.choices...), j)
+				alts := c.alts
+				if j > 0 {
+					alts++
+				}
+				next = append(next, combination{choices: choices, alts: alts})
+			}
+		}
+		sort.SliceStable(next, func(a, b int) bool {
+			return next[a].alts < next[b].alts
+		})
+		if len(next) > limit {
+			next = next[:limit]
+		}
+		combinations = next
+	}
+
+	results := make([]string, 0, len(combinations))
+	seen := make(map[string]bool)
+	for _, c := range combinations {
+		s := format(readings, c.choices)
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+		results = append(results, s)
+	}
+	return results
+}
+
+// 与 PinyinOfWord 相同的格式：汉字的拼音之间以空格分隔，非汉字部分保持原样
+func format_pinyin_readings(readings []pinyin_reading, choices []int) string {
+	py := ""
+	pre_space := false
+	for i, reading := range readings {
+		if reading.han {
+			if !pre_space {
+				py += " "
+			}
+			py += reading.options[choices[i]] + " "
+			pre_space = true
+		} else {
+			py += reading.options[choices[i]]
+			pre_space = false
+		}
+	}
+	return strings.TrimSpace(py)
+}
+
+// 与 PinyinInitialsOfWord 相同的格式：汉字取拼音的首字母，非汉字部分转小写并去除空白
+func format_pinyin_initials(readings []pinyin_reading, choices []int) string {
+	var builder strings.Builder
+	for i, reading := range readings {
+		option := reading.options[choices[i]]
+		if reading.han {
+			builder.WriteByte(option[0])
+		} else if !strings.ContainsFunc(option, unicode.IsSpace) {
+			builder.WriteString(strings.ToLower(option))
+		}
+	}
+	return builder.String()
+}
+
+/**
+ * 将词转换为拼音，多音字生成多个读音组合，第一个为首选读音
+ * @param limit 读音组合的最大数量，<=0 时为默认参数的 PinyinMaxReadings
+ * @return []string 拼音，拼音之间以空格分隔，非汉字部分保持原样
+ * @return ok 所有汉字是否都转换成功
+ */
+func AllPinyinOfWord(word string, limit int) ([]string, bool) {
+	if limit <= 0 {
+		limit = DefaultIndexOptions().PinyinMaxReadings
+	}
+	readings, ok := word_pinyin_readings(word)
+	return combine_pinyin_readings(readings, limit, format_pinyin_readings), ok
+}
+
+/**
+ * 将词转换为拼音首字母，多音字生成多个读音组合，第一个为首选读音
+ * @param limit 读音组合的最大数量，<=0 时为默认参数的 PinyinMaxReadings
+ * @return []string 拼音首字母
+ * @return ok 所有汉字是否都转换成功
+ */
+func AllPinyinInitialsOfWord(word string, limit int) ([]string, bool) {
+	if limit <= 0 {
+		limit = DefaultIndexOptions().PinyinMaxReadings
+	}
+	readings, ok := word_pinyin_readings(word)
+	return combine_pinyin_readings(readings, limit, format_pinyin_initials), ok
+}
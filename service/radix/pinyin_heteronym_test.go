@@ -0,0 +1,49 @@
+package radix
+
+import (
+	"slices"
+	"testing"
+)
+
+// 短语读音表固定的读音随切词传递到后缀，同一汉字串中的其他汉字只取首选读音
+func TestPinyinOverridesApplyToSuffixes(t *testing.T) {
+	db := open_test_indexdb(t, build_places_index(t, nil))
+	var pys []string
+	if err := db.Select(&pys, "SELECT word FROM index_words WHERE type = 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"chong qing shi", "qing shi", "chang sha shi", "yin hang da sha", "hang da sha", "zhong guo yin hang"} {
+		if !slices.Contains(pys, want) {
+			t.Errorf("pinyin index words missing %q: %v", want, pys)
+		}
+	}
+	for _, wrong := range []string{"zhong qing shi", "chong qing fu", "qing fu", "zhang sha shi", "chang sha fu", "yin xing da sha", "xing da sha", "heng da sha", "zhong guo yin xing"} {
+		if slices.Contains(pys, wrong) {
+			t.Errorf("pinyin index words contain the wrong reading %q", wrong)
+		}
+	}
+}
+
+func TestIndexWordPinyinReadings(t *testing.T) {
+	cases := []struct {
+		word, source string
+		want         []string
+	}{
+		{"行大厦", "银行大厦", []string{"hang da sha"}},
+		{"庆市", "重庆市", []string{"qing shi"}},
+		{"沙市", "长沙市", []string{"sha shi"}},
+		// 不在来源短语中时按索引词本身分析
+		{"重庆", "长沙市", []string{"chong qing"}},
+	}
+	for _, c := range cases {
+		readings, ok := index_word_pinyin_readings(c.word, c.source)
+		if !ok {
+			t.Errorf("index_word_pinyin_readings(%s, %s) not ok", c.word, c.source)
+			continue
+		}
+		if got := combine_pinyin_readings(readings, 4, format_pinyin_readings); !slices.Equal(got, c.want) {
+			t.Errorf("index_word_pinyin_readings(%s, %s) = %v, want %v", c.word, c.source, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package radix
+
+// 拼音音节切分：用户输入的拼音常常不带空格，如 "duolaameng"，按音节表切分为 "duo la a meng" 后才能召回 Type 1 拼音索引词
+
+import (
+	"sort"
+	"strings"
+)
+
+// 普通风格（不带声调）的全部拼音音节，ü 按 go-pinyin 的规则写作 v
+const pinyinSyllableTable = `
+a o e ai ei ao ou an en ang eng er
+ba bo bai bei bao ban ben bang beng bi bie biao bian bin bing bu
+pa po pai pei pao pou pan pen pang peng pi pie piao pian pin ping pu
+ma mo me mai mei mao mou man men mang meng mi mie miao miu mian min ming mu
+fa fo fei fou fan fen fang feng fu
+da de dai dei dao dou dan den dang deng dong di die diao diu dian ding du duo dui duan dun
+ta te tai tei tao tou tan tang teng tong ti tie tiao tian ting tu tuo tui tuan tun
+na ne nai nei nao nou nan nen nang neng nong ni nie niao niu nian nin niang ning nu nuo nuan nv nve
+la le lo lai lei lao lou lan lang leng long li lia lie liao liu lian lin liang ling lu luo luan lun lv lve
+ga ge gai gei gao gou gan gen gang geng gong gu gua guo guai gui guan gun guang
+ka ke kai kei kao kou kan ken kang keng kong ku kua kuo kuai kui kuan kun kuang
+ha he hai hei hao hou han hen hang heng hong hu hua huo huai hui huan hun huang
+ji jia jie jiao jiu jian jin jiang jing jiong ju jue juan jun
+qi qia qie qiao qiu qian qin qiang qing qiong qu que quan qun
+xi xia xie xiao xiu xian xin xiang xing xiong xu xue xuan xun
+zha zhe zhi zhai zhei zhao zhou zhan zhen zhang zheng zhong zhu zhua zhuo zhuai zhui zhuan zhun zhuang
+cha che chi chai chao chou chan chen chang cheng chong chu chua chuo chuai chui chuan chun chuang
+sha she shi shai shei shao shou shan shen shang sheng shu shua shuo shuai shui shuan shun shuang
+re ri rao rou ran ren rang reng rong ru rua ruo rui ruan run
+za ze zi zai zei zao zou zan zen zang zeng zong zu zuo zui zuan zun
+ca ce ci cai cao cou can cen cang ceng cong cu cuo cui cuan cun
+sa se si sai sao sou san sen sang seng song su suo sui suan sun
+ya yo ye yao you yan yin yang ying yong yi yu yue yuan yun
+wa wo wai wei wan wen wang weng wu
+`
+
+// 音节的最大长度，如 zhuang
+const pinyinSyllableMaxLen = 6
+
+// 单个拼音串最多枚举的切分数量，避免长串的切分数量爆炸
+const pinyinSegmentMaxCandidates = 32
+
+// 查询时每个拼音短语最多保留的切分数量
+const pinyinSegmentLimit = 4
+
+var pinyinSyllables = func() map[string]bool {
+	syllables := make(map[string]bool)
+	for _, s := range strings.Fields(pinyinSyllableTable) {
+		syllables[s] = true
+	}
+	return syllables
+}()
+
+func IsPinyinSyllable(s string) bool {
+	return pinyinSyllables[s]
+}
+
+// 是否只包含小写字母
+func is_lower_letters(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < 'a' || s[i] > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+/**
+ * 将不带空格的拼音串切分为音节，如 "xian" -> ["xian", "xi an"]
+ * 优先尝试较长的音节，按音节数量从少到多排序，音节数量相同时按字典序
+ * @param letters 小写字母组成的拼音串
+ * @return [][]string 所有可能的切分，无法切分时返回空
+ */
+func segment_pinyin_syllables(letters string) [][]string {
+	n := len(letters)
+	if !is_lower_letters(letters) {
+		return nil
+	}
+
+	// reachable[i] 表示从位置i开始的剩余部分能否完整切分，用于剪枝
+	reachable := make([]bool, n+1)
+	reachable[n] = true
+	for i := n - 1; i >= 0; i-- {
+		for l := 1; l <= pinyinSyllableMaxLen && i+l <= n; l++ {
+			if reachable[i+l] && pinyinSyllables[letters[i:i+l]] {
+				reachable[i] = true
+				break
+			}
+		}
+	}
+	if !reachable[0] {
+		return nil
+	}
+
+	results := make([][]string, 0)
+	path := make([]string, 0, n)
+	var dfs func(start int)
+	dfs = func(start int) {
+		if len(results) >= pinyinSegmentMaxCandidates {
+			return
+		}
+		if start == n {
+			results = append(results, append([]string{}, path...))
+			return
+		}
+		for l := min(pinyinSyllableMaxLen, n-start); l >= 1; l-- {
+			syllable := letters[start : start+l]
+			if !reachable[start+l] || !pinyinSyllables[syllable] {
+				continue
+			}
+			path = append(path, syllable)
+			dfs(start + l)
+			path = path[:len(path)-1]
+		}
+	}
+	dfs(0)
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if len(results[i]) != len(results[j]) {
+			return len(results[i]) < len(results[j])
+		}
+		return strings.Join(results[i], " ") < strings.Join(results[j], " ")
+	})
+	return results
+}
+
+/**
+ * 切分拼音输入，输入中已有的空格保留，每一段分别切分后组合
+ * 无法切分的段（如数字、非拼音字母串）保持原样
+ * @return []string 以空格分隔音节的拼音，按音节数量从少到多排序
+ */
+func split_pinyin_input(input string) []string {
+	candidates := []string{""}
+	for _, field := range strings.Fields(strings.ToLower(input)) {
+		alternatives := make([]string, 0)
+		for _, syllables := range segment_pinyin_syllables(field) {
+			alternatives = append(alternatives, strings.Join(syllables, " "))
+		}
+		if len(alternatives) == 0 {
+			alternatives = append(alternatives, field)
+		}
+
+		next := make([]string, 0, len(candidates)*len(alternatives))
+		for _, c := range candidates {
+			for _, a := range alternatives {
+				if len(next) >= pinyinSegmentMaxCandidates {
+					break
+				}
+				next = append(next, strings.TrimSpace(c+" "+a))
+			}
+		}
+		candidates = next
+	}
+	if len(candidates) == 1 && len(candidates[0]) == 0 {
+		return nil
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return len(strings.Fields(candidates[i])) < len(strings.Fields(candidates[j]))
+	})
+	return candidates
+}
+
+/**
+ * 切分拼音输入为最可能的音节序列，如 "duolaameng" -> "duo la a meng"
+ * 在索引 Type 1 拼音索引词中存在的切分排在前面，其余按音节数量从少到多
+ * @param input 拼音输入，可以带空格
+ * @param limit 返回的最大数量，<=0 时不限制
+ * @return []string 以空格分隔音节的拼音
+ * @return error 查询拼音索引词失败
+ */
+func (s *Searcher) SegmentPinyin(input string, limit int) ([]string, error) {
+	return s.view().segment_pinyin(input, limit)
+}
+
+func (s *searcher_view) segment_pinyin(input string, limit int) ([]string, error) {
+	candidates := split_pinyin_input(input)
+	if len(candidates) == 0 {
+		return candidates, nil
+	}
+
+	index_words, err := s.lookup_index_words(candidates, 1)
+	if err != nil {
+		return nil, err
+	}
+	vocab := make(map[string]bool, len(index_words))
+	for _, iw := range index_words {
+		vocab[iw.Word] = true
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return vocab[candidates[i]] && !vocab[candidates[j]]
+	})
+
+	if limit > 0 && len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates, nil
+}
@@ -0,0 +1,89 @@
+package radix
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSegmentPinyinSyllables(t *testing.T) {
+	cases := []struct {
+		letters string
+		want    []string // 全部切分，nil 表示无法切分
+	}{
+		{"xian", []string{"xian", "xi an"}},
+		{"zhongguo", []string{"zhong guo", "zhong gu o"}},
+		{"fangan", []string{"fan gan", "fang an"}},
+		{"duolaameng", []string{"duo la a meng", "du o la a meng"}},
+		{"xyz", nil},
+		{"Xian", nil},
+		{"", nil},
+	}
+	for _, c := range cases {
+		var got []string
+		for _, syllables := range segment_pinyin_syllables(c.letters) {
+			got = append(got, strings.Join(syllables, " "))
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("segment_pinyin_syllables(%q) = %q, want %q", c.letters, got, c.want)
+		}
+	}
+}
+
+// 切分数量有上限，长串只保留音节最少的切分
+func TestSegmentPinyinSyllablesLimit(t *testing.T) {
+	results := segment_pinyin_syllables(strings.Repeat("xian", 6))
+	if len(results) != pinyinSegmentMaxCandidates {
+		t.Fatalf("got %d segmentations, want %d", len(results), pinyinSegmentMaxCandidates)
+	}
+	if got := strings.Join(results[0], " "); got != strings.TrimSpace(strings.Repeat("xian ", 6)) {
+		t.Errorf("first segmentation %q, want six xian", got)
+	}
+	for i := 1; i < len(results); i++ {
+		if len(results[i]) < len(results[i-1]) {
+			t.Fatalf("segmentation %d has fewer syllables than the one before", i)
+		}
+	}
+}
+
+// 有歧义的拼音输入，索引中存在的切分排在前面
+func TestSegmentPinyinPrefersIndexWords(t *testing.T) {
+	cases := []struct {
+		names []string
+		input string
+		want  string
+	}{
+		{[]string{"西安"}, "xian", "xi an"},
+		{[]string{"先锋书店"}, "xian", "xian"},
+		{[]string{"方案"}, "fangan", "fang an"},
+		{[]string{"先锋书店"}, "fangan", "fan gan"},
+	}
+	for _, c := range cases {
+		s := open_test_searcher(t, build_test_index(t, map[string][]string{"words": c.names}, nil))
+		got, err := s.SegmentPinyin(c.input, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || got[0] != c.want {
+			t.Errorf("%v: SegmentPinyin(%s) = %q, want %q", c.names, c.input, got, c.want)
+		}
+	}
+}
+
+// 查询拼音索引词失败时返回错误，不按索引中没有该切分处理
+func TestSegmentPinyinLookupError(t *testing.T) {
+	index_path := build_places_index(t, nil)
+	db := open_test_indexdb(t, index_path)
+	if _, err := db.Exec("ALTER TABLE index_words RENAME TO broken_index_words"); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	s := open_test_searcher(t, index_path)
+	if got, err := s.SegmentPinyin("yinhang", 1); err == nil {
+		t.Errorf("SegmentPinyin without index_words = %q, want an error", got)
+	}
+	if _, err := recall_route_pinyin(s.view(), NewIndexSentence("yinhang dasha")); err == nil {
+		t.Error("pinyin route without index_words returned no error")
+	}
+}
@@ -4,46 +4,42 @@ import (
 	"errors"
 	"sort"
 	"strings"
+	"unicode/utf8"
 )
 
-// contains 检查切片中是否包含某个元素
-func contains(slice []uint32, val uint32) bool {
-	for _, item := range slice {
-		if item == val {
-			return true
-		}
-	}
-	return false
-}
-
-// addIfNotExist 向切片添加一个元素，如果该元素不存在
-func addIfNotExist(slice []uint32, val uint32) []uint32 {
-	if !contains(slice, val) {
-		slice = append(slice, val)
-	}
-	return slice
-}
-
 // WalkFn is used when walking the tree. Takes a
 // key and value, returning if iteration should
 // be terminated.
-type WalkFn func(s string, v []uint32) bool
+type WalkFn[V any] func(s string, v V) bool
+
+// MergeFn 合并同一个键的旧值与新值，返回合并后的值；不能修改 old，事务中 old 可能仍被其他快照使用
+type MergeFn[V any] func(old V, new V) V
 
 // leafNode is used to represent a value
-type leafNode struct {
+type leafNode[V any] struct {
 	key string
-	val []uint32
+	val V
 }
 
 // edge is used to represent an edge node
-type edge struct {
-	label byte
-	node  *node
+// label 为子节点 prefix 的首个 rune，保证汉字等多字节字符不会在字节中间分叉
+type edge[V any] struct {
+	label rune
+	node  *node[V]
+}
+
+// labelOf 返回字符串首个 rune 作为边的标签；非法的 utf8 字节按字节区分，排在所有合法 rune 之后
+func labelOf(s string) rune {
+	r, size := utf8.DecodeRuneInString(s)
+	if r == utf8.RuneError && size <= 1 && len(s) > 0 {
+		return utf8.MaxRune + 1 + rune(s[0])
+	}
+	return r
 }
 
-type node struct {
+type node[V any] struct {
 	// leaf is used to store possible leaf
-	leaf *leafNode
+	leaf *leafNode[V]
 
 	// prefix is the common prefix we ignore
 	prefix string
@@ -51,25 +47,28 @@ type node struct {
 	// Edges should be stored in-order for iteration.
 	// We avoid a fully materialized slice to save memory,
 	// since in most cases we expect to be sparse
-	edges edges
+	edges edges[V]
+
+	// 子树中权重最大的 k 个键，见 EnableTopK
+	top []topEntry[V]
 }
 
-func (n *node) isLeaf() bool {
+func (n *node[V]) isLeaf() bool {
 	return n.leaf != nil
 }
 
-func (n *node) addEdge(e edge) {
+func (n *node[V]) addEdge(e edge[V]) {
 	num := len(n.edges)
 	idx := sort.Search(num, func(i int) bool {
 		return n.edges[i].label >= e.label
 	})
 
-	n.edges = append(n.edges, edge{})
+	n.edges = append(n.edges, edge[V]{})
 	copy(n.edges[idx+1:], n.edges[idx:])
 	n.edges[idx] = e
 }
 
-func (n *node) findEdgeIndex(label byte) (int, bool) {
+func (n *node[V]) findEdgeIndex(label rune) (int, bool) {
 	for i, e := range n.edges {
 		if e.label == label {
 			return i, true
@@ -78,7 +77,7 @@ func (n *node) findEdgeIndex(label byte) (int, bool) {
 	return -1, false
 }
 
-func (n *node) updateEdge(label byte, node *node) error {
+func (n *node[V]) updateEdge(label rune, node *node[V]) error {
 	idx, found := n.findEdgeIndex(label)
 	if !found {
 		return errors.New("edge not found")
@@ -87,7 +86,7 @@ func (n *node) updateEdge(label byte, node *node) error {
 	return nil
 }
 
-func (n *node) getEdge(label byte) *node {
+func (n *node[V]) getEdge(label rune) *node[V] {
 	num := len(n.edges)
 	idx := sort.Search(num, func(i int) bool {
 		return n.edges[i].label >= label
@@ -98,33 +97,33 @@ func (n *node) getEdge(label byte) *node {
 	return nil
 }
 
-func (n *node) delEdge(label byte) {
+func (n *node[V]) delEdge(label rune) {
 	num := len(n.edges)
 	idx := sort.Search(num, func(i int) bool {
 		return n.edges[i].label >= label
 	})
 	if idx < num && n.edges[idx].label == label {
 		copy(n.edges[idx:], n.edges[idx+1:])
-		n.edges[len(n.edges)-1] = edge{}
+		n.edges[len(n.edges)-1] = edge[V]{}
 		n.edges = n.edges[:len(n.edges)-1]
 	}
 }
 
-type edges []edge
+type edges[V any] []edge[V]
 
-func (e edges) Len() int {
+func (e edges[V]) Len() int {
 	return len(e)
 }
 
-func (e edges) Less(i, j int) bool {
+func (e edges[V]) Less(i, j int) bool {
 	return e[i].label < e[j].label
 }
 
-func (e edges) Swap(i, j int) {
+func (e edges[V]) Swap(i, j int) {
 	e[i], e[j] = e[j], e[i]
 }
 
-func (e edges) Sort() {
+func (e edges[V]) Sort() {
 	sort.Sort(e)
 }
 
@@ -132,20 +131,27 @@ func (e edges) Sort() {
 // Dictionary abstract data type. The main advantage over
 // a standard hash map is prefix-based lookups and
 // ordered iteration,
-type Tree struct {
-	root *node
-	size int
+// 叶子节点的值类型为 V，同一个键重复插入时按 merge 合并，如字典词ID列表见 NewPostingTree
+type Tree[V any] struct {
+	root  *node[V]
+	size  int
+	merge MergeFn[V]
+
+	// 每个节点缓存的 top-k 大小及叶子权重，topK 为 0 时不缓存
+	topK  int
+	weigh WeightFn[V]
 }
 
 // New returns an empty Tree
-func New() *Tree {
-	return NewFromMap(nil)
+// merge 用于合并同一个键的旧值与新值，为 nil 时新值覆盖旧值
+func New[V any](merge MergeFn[V]) *Tree[V] {
+	return &Tree[V]{root: &node[V]{}, merge: merge}
 }
 
 // NewFromMap returns a new tree containing the keys
 // from an existing map
-func NewFromMap(m map[string]uint32) *Tree {
-	t := &Tree{root: &node{}}
+func NewFromMap[V any](m map[string]V, merge MergeFn[V]) *Tree[V] {
+	t := New(merge)
 	for k, v := range m {
 		t.Insert(k, v)
 	}
@@ -153,12 +159,12 @@ func NewFromMap(m map[string]uint32) *Tree {
 }
 
 // Len is used to return the number of elements in the tree
-func (t *Tree) Len() int {
+func (t *Tree[V]) Len() int {
 	return t.size
 }
 
 // longestPrefix finds the length of the shared prefix
-// of two strings
+// of two strings, 长度回退到 rune 边界，节点不会在多字节字符中间分裂
 func longestPrefix(k1, k2 string) int {
 	max := len(k1)
 	if l := len(k2); l < max {
@@ -170,74 +176,101 @@ func longestPrefix(k1, k2 string) int {
 			break
 		}
 	}
-	return i
+	pos := 0
+	for pos < i {
+		_, size := utf8.DecodeRuneInString(k1[pos:])
+		if pos+size > i {
+			break
+		}
+		pos += size
+	}
+	return pos
 }
 
-// Optimize 递归优化树，合并具有共同前缀的子节点
-func (t *Tree) Optimize() {
-	t.root.optimizeNode()
+// Optimize 压缩索引树：删除没有叶子也没有子节点的空节点，将没有叶子且只有一个子节点的节点与子节点合并
+// 会原地修改节点，已发布的索引树不能调用
+func (t *Tree[V]) Optimize() {
+	t.root.optimizeNode(true)
 }
 
-func (n *node) optimizeNode() {
-	// 遍历所有子节点，尝试合并
-	for i := 0; i < len(n.edges)-1; i++ {
-		current := n.edges[i].node
-		next := n.edges[i+1].node
-
-		// 检查当前节点和下一个节点是否可以合并
-		if current != nil && next != nil && current.prefix == next.prefix {
-			// 合并逻辑，这里简单地扩展prefix并重新分配子节点
-			current.prefix += next.prefix
-			current.edges = append(current.edges, next.edges...)
-			// 删除下一个节点
-			n.edges = append(n.edges[:i+1], n.edges[i+2:]...)
-			// 递减索引以重新检查当前位置
-			i--
+// 后序处理，子节点先压缩；返回节点是否为空，空节点由父节点删除
+func (n *node[V]) optimizeNode(isRoot bool) bool {
+	kept := n.edges[:0]
+	for _, e := range n.edges {
+		if !e.node.optimizeNode(false) {
+			kept = append(kept, e)
 		}
-		current.optimizeNode()
 	}
+	for i := len(kept); i < len(n.edges); i++ {
+		n.edges[i] = edge[V]{}
+	}
+	n.edges = kept
+
+	if isRoot {
+		return false
+	}
+	if !n.isLeaf() && len(n.edges) == 0 {
+		return true
+	}
+	// 子节点已压缩，合并一次即可
+	if !n.isLeaf() && len(n.edges) == 1 {
+		n.mergeChild()
+	}
+	return false
 }
 
 // Insert is used to add a newentry or update
 // an existing entry. Returns true if an existing record is updated.
-func (t *Tree) Insert(s string, v uint32) (bool, error) {
-	var parent *node
+func (t *Tree[V]) Insert(s string, v V) (bool, error) {
+	var parent *node[V]
+	var path []*node[V] // 从根节点到插入位置的节点，用于更新 top-k 缓存
 	n := t.root
 	search := s
 	for {
+		if t.topK > 0 {
+			path = append(path, n)
+		}
+
 		// Handle key exhaution
 		if len(search) == 0 {
 			if n.isLeaf() {
-				n.leaf.val = addIfNotExist(n.leaf.val, v)
+				if t.merge != nil {
+					n.leaf.val = t.merge(n.leaf.val, v)
+				} else {
+					n.leaf.val = v
+				}
+				t.topInsert(path, n.leaf)
 				return true, nil
 			}
 
-			n.leaf = &leafNode{
+			n.leaf = &leafNode[V]{
 				key: s,
-				val: []uint32{v},
+				val: v,
 			}
 			t.size++
+			t.topInsert(path, n.leaf)
 			return false, nil
 		}
 
 		// Look for the edge
 		parent = n
-		n = n.getEdge(search[0])
+		n = n.getEdge(labelOf(search))
 
 		// No edge, create one
 		if n == nil {
-			e := edge{
-				label: search[0],
-				node: &node{
-					leaf: &leafNode{
+			e := edge[V]{
+				label: labelOf(search),
+				node: &node[V]{
+					leaf: &leafNode[V]{
 						key: s,
-						val: []uint32{v},
+						val: v,
 					},
 					prefix: search,
 				},
 			}
 			parent.addEdge(e)
 			t.size++
+			t.topInsert(append(path, e.node), e.node.leaf)
 			return false, nil
 		}
 
@@ -250,54 +283,76 @@ func (t *Tree) Insert(s string, v uint32) (bool, error) {
 
 		// Split the node
 		t.size++
-		child := &node{
+		child := &node[V]{
 			prefix: search[:commonPrefix],
 		}
-		err := parent.updateEdge(search[0], child)
+		err := parent.updateEdge(labelOf(search), child)
 		if err != nil {
 			return false, err
 		}
 
 		// Restore the existing node
-		child.addEdge(edge{
-			label: n.prefix[commonPrefix],
+		child.addEdge(edge[V]{
+			label: labelOf(n.prefix[commonPrefix:]),
 			node:  n,
 		})
 		n.prefix = n.prefix[commonPrefix:]
 
 		// Create a new leaf node
-		leaf := &leafNode{
+		leaf := &leafNode[V]{
 			key: s,
-			val: []uint32{v},
+			val: v,
 		}
 
 		// If the new key is a subset, add to this node
 		search = search[commonPrefix:]
 		if len(search) == 0 {
 			child.leaf = leaf
+			t.topSplit(path, child, leaf)
 			return false, nil
 		}
 
 		// Create a new edge for the node
-		child.addEdge(edge{
-			label: search[0],
-			node: &node{
+		child.addEdge(edge[V]{
+			label: labelOf(search),
+			node: &node[V]{
 				leaf:   leaf,
 				prefix: search,
 			},
 		})
+		t.topSplit(path, child, leaf)
 		return false, nil
 	}
 }
 
+// 分裂出的节点用其子节点计算缓存，再更新其上的路径
+func (t *Tree[V]) topSplit(path []*node[V], split *node[V], leaf *leafNode[V]) {
+	if t.topK <= 0 {
+		return
+	}
+	for _, e := range split.edges {
+		if e.node.leaf == leaf {
+			e.node.top = computeTop(e.node, t.topK, t.weigh)
+		}
+	}
+	split.top = computeTop(split, t.topK, t.weigh)
+	t.topInsert(path, leaf)
+}
+
 // Delete is used to delete a key, returning the previous
 // value and if it was deleted
-func (t *Tree) Delete(s string) ([]uint32, bool) {
-	var parent *node
-	var label byte
+func (t *Tree[V]) Delete(s string) (V, bool) {
+	var parent *node[V]
+	var label rune
+	var zero V
+	var path []*node[V] // 从根节点到被删除的节点，用于更新 top-k 缓存
 	n := t.root
 	search := s
 	for {
+		if t.topK > 0 {
+			path = append(path, n)
+		}
+
 		// Check for key exhaution
 		if len(search) == 0 {
 			if !n.isLeaf() {
@@ -308,7 +363,7 @@ func (t *Tree) Delete(s string) ([]uint32, bool) {
 
 		// Look for an edge
 		parent = n
-		label = search[0]
+		label = labelOf(search)
 		n = n.getEdge(label)
 		if n == nil {
 			break
@@ -321,7 +376,7 @@ func (t *Tree) Delete(s string) ([]uint32, bool) {
 			break
 		}
 	}
-	return nil, false
+	return zero, false
 
 DELETE:
 	// Delete the leaf
@@ -343,6 +398,7 @@ DELETE:
 	if parent != nil && parent != t.root && len(parent.edges) == 1 && !parent.isLeaf() {
 		parent.mergeChild()
 	}
+	t.topDelete(path, s)
 
 	return leaf.val, true
 }
@@ -350,18 +406,27 @@ DELETE:
 // DeletePrefix is used to delete the subtree under a prefix
 // Returns how many nodes were deleted
 // Use this to delete large subtrees efficiently
-func (t *Tree) DeletePrefix(s string) int {
+func (t *Tree[V]) DeletePrefix(s string) int {
 	return t.deletePrefix(nil, t.root, s)
 }
 
 // delete does a recursive deletion
-func (t *Tree) deletePrefix(parent, n *node, prefix string) int {
+func (t *Tree[V]) deletePrefix(parent, n *node[V], prefix string) int {
+	deleted := t.deletePrefixNode(parent, n, prefix)
+	// 子树中被删除的键可能在路径上任意节点的缓存中，回溯时重新计算
+	if deleted > 0 && t.topK > 0 {
+		n.top = computeTop(n, t.topK, t.weigh)
+	}
+	return deleted
+}
+
+func (t *Tree[V]) deletePrefixNode(parent, n *node[V], prefix string) int {
 	// Check for key exhaustion
 	if len(prefix) == 0 {
 		// Remove the leaf node
 		subTreeSize := 0
 		//recursively walk from all edges of the node to be deleted
-		recursiveWalk(n, func(s string, v []uint32) bool {
+		recursiveWalk(n, func(s string, v V) bool {
 			subTreeSize++
 			return false
 		})
@@ -370,6 +435,11 @@ func (t *Tree) deletePrefix(parent, n *node, prefix string) int {
 		}
 		n.edges = nil // deletes the entire subtree
 
+		// 空节点从父节点中删除，不保留没有叶子也没有子节点的边
+		if parent != nil {
+			parent.delEdge(labelOf(n.prefix))
+		}
+
 		// Check if we should merge the parent's other child
 		if parent != nil && parent != t.root && len(parent.edges) == 1 && !parent.isLeaf() {
 			parent.mergeChild()
@@ -379,7 +449,7 @@ func (t *Tree) deletePrefix(parent, n *node, prefix string) int {
 	}
 
 	// Look for an edge
-	label := prefix[0]
+	label := labelOf(prefix)
 	child := n.getEdge(label)
 	if child == nil || (!strings.HasPrefix(child.prefix, prefix) && !strings.HasPrefix(prefix, child.prefix)) {
 		return 0
@@ -394,17 +464,18 @@ func (t *Tree) deletePrefix(parent, n *node, prefix string) int {
 	return t.deletePrefix(n, child, prefix)
 }
 
-func (n *node) mergeChild() {
+func (n *node[V]) mergeChild() {
 	e := n.edges[0]
 	child := e.node
 	n.prefix = n.prefix + child.prefix
 	n.leaf = child.leaf
 	n.edges = child.edges
+	n.top = child.top
 }
 
 // Get is used to lookup a specific key, returning
 // the value and if it was found
-func (t *Tree) Get(s string) ([]uint32, bool) {
+func (t *Tree[V]) Get(s string) (V, bool) {
 	n := t.root
 	search := s
 	for {
@@ -417,7 +488,7 @@ func (t *Tree) Get(s string) ([]uint32, bool) {
 		}
 
 		// Look for an edge
-		n = n.getEdge(search[0])
+		n = n.getEdge(labelOf(search))
 		if n == nil {
 			break
 		}
@@ -429,13 +500,14 @@ func (t *Tree) Get(s string) ([]uint32, bool) {
 			break
 		}
 	}
-	return nil, false
+	var zero V
+	return zero, false
 }
 
 // LongestPrefix is like Get, but instead of an
 // exact match, it will return the longest prefix match.
-func (t *Tree) LongestPrefix(s string) (string, []uint32, bool) {
-	var last *leafNode
+func (t *Tree[V]) LongestPrefix(s string) (string, V, bool) {
+	var last *leafNode[V]
 	n := t.root
 	search := s
 	for {
@@ -450,7 +522,7 @@ func (t *Tree) LongestPrefix(s string) (string, []uint32, bool) {
 		}
 
 		// Look for an edge
-		n = n.getEdge(search[0])
+		n = n.getEdge(labelOf(search))
 		if n == nil {
 			break
 		}
@@ -465,11 +537,12 @@ func (t *Tree) LongestPrefix(s string) (string, []uint32, bool) {
 	if last != nil {
 		return last.key, last.val, true
 	}
-	return "", nil, false
+	var zero V
+	return "", zero, false
 }
 
 // Minimum is used to return the minimum value in the tree
-func (t *Tree) Minimum() (string, []uint32, bool) {
+func (t *Tree[V]) Minimum() (string, V, bool) {
 	n := t.root
 	for {
 		if n.isLeaf() {
@@ -481,11 +554,12 @@ func (t *Tree) Minimum() (string, []uint32, bool) {
 			break
 		}
 	}
-	return "", nil, false
+	var zero V
+	return "", zero, false
 }
 
 // Maximum is used to return the maximum value in the tree
-func (t *Tree) Maximum() (string, []uint32, bool) {
+func (t *Tree[V]) Maximum() (string, V, bool) {
 	n := t.root
 	for {
 		if num := len(n.edges); num > 0 {
@@ -497,16 +571,17 @@ func (t *Tree) Maximum() (string, []uint32, bool) {
 		}
 		break
 	}
-	return "", nil, false
+	var zero V
+	return "", zero, false
 }
 
 // Walk is used to walk the tree
-func (t *Tree) Walk(fn WalkFn) {
+func (t *Tree[V]) Walk(fn WalkFn[V]) {
 	recursiveWalk(t.root, fn)
 }
 
 // WalkPrefix is used to walk the tree under a prefix
-func (t *Tree) WalkPrefix(prefix string, fn WalkFn) {
+func (t *Tree[V]) WalkPrefix(prefix string, fn WalkFn[V]) {
 	n := t.root
 	search := prefix
 	for {
@@ -517,7 +592,7 @@ func (t *Tree) WalkPrefix(prefix string, fn WalkFn) {
 		}
 
 		// Look for an edge
-		n = n.getEdge(search[0])
+		n = n.getEdge(labelOf(search))
 		if n == nil {
 			return
 		}
@@ -539,7 +614,7 @@ func (t *Tree) WalkPrefix(prefix string, fn WalkFn) {
 // from the root down to a given leaf. Where WalkPrefix walks
 // all the entries *under* the given prefix, this walks the
 // entries *above* the given prefix.
-func (t *Tree) WalkPath(path string, fn WalkFn) {
+func (t *Tree[V]) WalkPath(path string, fn WalkFn[V]) {
 	n := t.root
 	search := path
 	for {
@@ -554,7 +629,7 @@ func (t *Tree) WalkPath(path string, fn WalkFn) {
 		}
 
 		// Look for an edge
-		n = n.getEdge(search[0])
+		n = n.getEdge(labelOf(search))
 		if n == nil {
 			return
 		}
@@ -570,7 +645,7 @@ func (t *Tree) WalkPath(path string, fn WalkFn) {
 
 // recursiveWalk is used to do a pre-order walk of a node
 // recursively. Returns true if the walk should be aborted
-func recursiveWalk(n *node, fn WalkFn) bool {
+func recursiveWalk[V any](n *node[V], fn WalkFn[V]) bool {
 	// Visit the leaf values if any
 	if n.leaf != nil && fn(n.leaf.key, n.leaf.val) {
 		return true
@@ -603,9 +678,9 @@ func recursiveWalk(n *node, fn WalkFn) bool {
 }
 
 // ToMap is used to walk the tree and convert it into a map
-func (t *Tree) ToMap() map[string][]uint32 {
-	out := make(map[string][]uint32, t.size)
-	t.Walk(func(k string, v []uint32) bool {
+func (t *Tree[V]) ToMap() map[string]V {
+	out := make(map[string]V, t.size)
+	t.Walk(func(k string, v V) bool {
 		out[k] = v
 		return false
 	})
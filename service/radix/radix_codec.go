@@ -0,0 +1,237 @@
+package radix
+
+// 索引树的二进制序列化：按遍历顺序写出全部键值，键使用前缀压缩（front coding），末尾附 CRC32 校验
+//
+// 格式（整数均为 uvarint）：
+//   magic "RDXT" | version(1字节) | count
+//   count 个条目：与上一个键相同的前缀字节数 | 剩余后缀长度 | 后缀 | 值长度 | 值
+//   crc32(IEEE，小端4字节，覆盖之前的全部字节)
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+const (
+	treeCodecMagic   = "RDXT"
+	treeCodecVersion = 1
+	treeCodecMaxLen  = 1 << 30 // 单个键或值的最大字节数，防止损坏的数据导致超大内存分配
+)
+
+var ErrTreeChecksum = errors.New("radix tree checksum mismatch")
+
+// Codec 叶子节点值的编解码，Encode 将值追加到 buf 后返回
+type Codec[V any] struct {
+	Encode func(buf []byte, v V) []byte
+	Decode func(data []byte) (V, error)
+}
+
+// PostingsCodec 字典词ID列表的编解码：数量，之后为各ID与前一个ID的差值
+var PostingsCodec = Codec[Postings]{
+	Encode: func(buf []byte, p Postings) []byte {
+		buf = binary.AppendUvarint(buf, uint64(len(p)))
+		var prev uint32
+		for _, id := range p {
+			buf = binary.AppendUvarint(buf, uint64(id-prev))
+			prev = id
+		}
+		return buf
+	},
+	Decode: func(data []byte) (Postings, error) {
+		count, n := binary.Uvarint(data)
+		if n <= 0 || count > uint64(len(data)) {
+			return nil, fmt.Errorf("invalid postings length")
+		}
+		data = data[n:]
+		p := make(Postings, 0, count)
+		var prev uint64
+		for i := uint64(0); i < count; i++ {
+			delta, n := binary.Uvarint(data)
+			if n <= 0 {
+				return nil, fmt.Errorf("invalid postings delta")
+			}
+			data = data[n:]
+			prev += delta
+			p = append(p, uint32(prev))
+		}
+		return p, nil
+	},
+}
+
+/**
+ * 将索引树写入 w
+ * @param codec 值的编解码
+ * @return int64 写入的字节数
+ */
+func (t *Tree[V]) WriteTo(w io.Writer, codec Codec[V]) (int64, error) {
+	bw := bufio.NewWriter(w)
+	crc := crc32.NewIEEE()
+	out := io.MultiWriter(bw, crc)
+	var written int64
+
+	buf := make([]byte, 0, 256)
+	buf = append(buf, treeCodecMagic...)
+	buf = append(buf, treeCodecVersion)
+	buf = binary.AppendUvarint(buf, uint64(t.size))
+
+	var err error
+	prev := ""
+	t.Walk(func(k string, v V) bool {
+		shared := longestCommonBytes(prev, k)
+		buf = binary.AppendUvarint(buf, uint64(shared))
+		buf = binary.AppendUvarint(buf, uint64(len(k)-shared))
+		buf = append(buf, k[shared:]...)
+		val := codec.Encode(nil, v)
+		buf = binary.AppendUvarint(buf, uint64(len(val)))
+		buf = append(buf, val...)
+		prev = k
+
+		if len(buf) >= 64*1024 {
+			var n int
+			n, err = out.Write(buf)
+			written += int64(n)
+			buf = buf[:0]
+		}
+		return err != nil
+	})
+	if err != nil {
+		return written, err
+	}
+
+	n, err := out.Write(buf)
+	written += int64(n)
+	if err != nil {
+		return written, err
+	}
+	n, err = bw.Write(binary.LittleEndian.AppendUint32(nil, crc.Sum32()))
+	written += int64(n)
+	if err != nil {
+		return written, err
+	}
+	return written, bw.Flush()
+}
+
+/**
+ * 从 r 读取 WriteTo 写出的索引树，校验失败时返回 ErrTreeChecksum
+ * r 未实现 io.ByteReader 时内部使用 bufio 读取，可能会多读取 r 中树之后的数据
+ * @param codec 值的编解码
+ * @param merge 返回的索引树使用的合并函数
+ */
+func ReadTree[V any](r io.Reader, codec Codec[V], merge MergeFn[V]) (*Tree[V], error) {
+	br, ok := r.(byte_reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	cr := &checksum_reader{br: br, crc: crc32.NewIEEE()}
+
+	header := make([]byte, len(treeCodecMagic)+1)
+	if _, err := io.ReadFull(cr, header); err != nil {
+		return nil, fmt.Errorf("failed to read radix tree header: %w", err)
+	}
+	if string(header[:len(treeCodecMagic)]) != treeCodecMagic {
+		return nil, fmt.Errorf("invalid radix tree magic %q", header[:len(treeCodecMagic)])
+	}
+	if header[len(treeCodecMagic)] != treeCodecVersion {
+		return nil, fmt.Errorf("unsupported radix tree version %d", header[len(treeCodecMagic)])
+	}
+	count, err := binary.ReadUvarint(cr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read radix tree size: %w", err)
+	}
+
+	t := New(merge)
+	key := make([]byte, 0, 64)
+	val := make([]byte, 0, 64)
+	for i := uint64(0); i < count; i++ {
+		shared, err := binary.ReadUvarint(cr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read radix tree entry %d: %w", i, err)
+		}
+		if shared > uint64(len(key)) {
+			return nil, fmt.Errorf("invalid shared prefix at entry %d", i)
+		}
+		key, err = cr.read_bytes(key[:shared])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read radix tree key %d: %w", i, err)
+		}
+		val, err = cr.read_bytes(val[:0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read radix tree value %d: %w", i, err)
+		}
+		v, err := codec.Decode(val)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode radix tree value %d: %w", i, err)
+		}
+		t.Insert(string(key), v)
+	}
+
+	sum := cr.crc.Sum32()
+	trailer := make([]byte, 4)
+	if _, err := io.ReadFull(br, trailer); err != nil {
+		return nil, fmt.Errorf("failed to read radix tree checksum: %w", err)
+	}
+	if binary.LittleEndian.Uint32(trailer) != sum {
+		return nil, ErrTreeChecksum
+	}
+	return t, nil
+}
+
+// 两个字符串相同前缀的字节数
+func longestCommonBytes(k1, k2 string) int {
+	n := min(len(k1), len(k2))
+	for i := 0; i < n; i++ {
+		if k1[i] != k2[i] {
+			return i
+		}
+	}
+	return n
+}
+
+type byte_reader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// 读取时同步计算 CRC32
+type checksum_reader struct {
+	br  byte_reader
+	crc hash.Hash32
+	one [1]byte
+}
+
+func (cr *checksum_reader) Read(p []byte) (int, error) {
+	n, err := cr.br.Read(p)
+	cr.crc.Write(p[:n])
+	return n, err
+}
+
+func (cr *checksum_reader) ReadByte() (byte, error) {
+	b, err := cr.br.ReadByte()
+	if err == nil {
+		cr.one[0] = b
+		cr.crc.Write(cr.one[:])
+	}
+	return b, err
+}
+
+// 读取 uvarint 长度及其后的字节，追加到 buf 后返回
+func (cr *checksum_reader) read_bytes(buf []byte) ([]byte, error) {
+	size, err := binary.ReadUvarint(cr)
+	if err != nil {
+		return nil, err
+	}
+	if size > treeCodecMaxLen {
+		return nil, fmt.Errorf("length %d exceeds limit", size)
+	}
+	start := len(buf)
+	buf = append(buf, make([]byte, size)...)
+	if _, err := io.ReadFull(cr, buf[start:]); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
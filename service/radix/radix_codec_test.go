@@ -0,0 +1,83 @@
+package radix
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func randomPostingTree(r *rand.Rand, count int) *Tree[Postings] {
+	tree := NewPostingTree()
+	for i := 0; i < count; i++ {
+		tree.Insert(randomTreeKey(r), Postings{uint32(r.Intn(100000))})
+	}
+	return tree
+}
+
+func encodeTree(t *testing.T, tree *Tree[Postings]) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	n, err := tree.WriteTo(&buf, PostingsCodec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Fatalf("WriteTo returned %d bytes, wrote %d", n, buf.Len())
+	}
+	return buf.Bytes()
+}
+
+// 随机索引树写出后读回，键值完全相同
+func TestTreeCodecRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(5))
+	for _, count := range []int{0, 1, 10, 3000} {
+		tree := randomPostingTree(r, count)
+		data := encodeTree(t, tree)
+
+		got, err := ReadTree(bytes.NewReader(data), PostingsCodec, MergePostings)
+		if err != nil {
+			t.Fatalf("ReadTree with %d keys: %v", count, err)
+		}
+		if got.Len() != tree.Len() {
+			t.Fatalf("Len() = %d, want %d", got.Len(), tree.Len())
+		}
+		if !reflect.DeepEqual(got.ToMap(), tree.ToMap()) {
+			t.Fatalf("round trip of %d keys changed the tree", count)
+		}
+		if err := got.Validate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// 任意一个字节被修改都不能读出索引树；修改键的内容或校验和时返回 ErrTreeChecksum
+func TestTreeCodecRejectsFlippedByte(t *testing.T) {
+	tree := New[Postings](MergePostings)
+	for i, k := range []string{"中国", "中国银行", "哆啦A梦", "银行大厦", "dlam"} {
+		tree.Insert(k, Postings{uint32(i + 1), uint32(i + 100)})
+	}
+	data := encodeTree(t, tree)
+
+	for i := range data {
+		corrupt := bytes.Clone(data)
+		corrupt[i] ^= 0x01
+		if _, err := ReadTree(bytes.NewReader(corrupt), PostingsCodec, MergePostings); err == nil {
+			t.Errorf("ReadTree accepted data with byte %d flipped", i)
+		}
+	}
+
+	for _, pos := range []int{strings.Index(string(data), "银行大厦") + 1, len(data) - 1} {
+		corrupt := bytes.Clone(data)
+		corrupt[pos] ^= 0x80
+		if _, err := ReadTree(bytes.NewReader(corrupt), PostingsCodec, MergePostings); !errors.Is(err, ErrTreeChecksum) {
+			t.Errorf("ReadTree with byte %d flipped returned %v, want ErrTreeChecksum", pos, err)
+		}
+	}
+
+	if _, err := ReadTree(bytes.NewReader(data[:len(data)-2]), PostingsCodec, MergePostings); err == nil {
+		t.Error("ReadTree accepted truncated data")
+	}
+}
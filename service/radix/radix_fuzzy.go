@@ -0,0 +1,68 @@
+package radix
+
+import (
+	"sort"
+)
+
+// FuzzyMatch 模糊查询命中的键、值及与查询的编辑距离
+type FuzzyMatch[V any] struct {
+	Key      string
+	Value    V
+	Distance int
+}
+
+/**
+ * FuzzySearch 查询与 query 的编辑距离（Levenshtein，按 rune 计算）不超过 maxEdits 的全部键
+ * 沿树向下遍历时，每经过一个 rune 计算一行编辑距离，整行的最小值超过 maxEdits 时剪枝
+ * @return []FuzzyMatch 按编辑距离从小到大排序，距离相同时按键排序
+ */
+func (t *Tree[V]) FuzzySearch(query string, maxEdits int) []FuzzyMatch[V] {
+	results := make([]FuzzyMatch[V], 0)
+	if maxEdits < 0 {
+		return results
+	}
+
+	target := []rune(query)
+	row := make([]int, len(target)+1)
+	for i := range row {
+		row[i] = i
+	}
+	t.root.fuzzySearch(target, row, maxEdits, &results)
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Distance != results[j].Distance {
+			return results[i].Distance < results[j].Distance
+		}
+		return results[i].Key < results[j].Key
+	})
+	return results
+}
+
+// fuzzySearch 在 row 的基础上消费节点的 prefix，row[i] 为已消费的 rune 与 target[:i] 的编辑距离
+func (n *node[V]) fuzzySearch(target []rune, row []int, maxEdits int, results *[]FuzzyMatch[V]) {
+	for _, r := range n.prefix {
+		next := make([]int, len(row))
+		next[0] = row[0] + 1
+		min_dist := next[0]
+		for i := 1; i < len(row); i++ {
+			cost := 1
+			if target[i-1] == r {
+				cost = 0
+			}
+			next[i] = min(next[i-1]+1, row[i]+1, row[i-1]+cost)
+			min_dist = min(min_dist, next[i])
+		}
+		// 继续向下只会增加编辑距离
+		if min_dist > maxEdits {
+			return
+		}
+		row = next
+	}
+
+	if n.leaf != nil && row[len(row)-1] <= maxEdits {
+		*results = append(*results, FuzzyMatch[V]{Key: n.leaf.key, Value: n.leaf.val, Distance: row[len(row)-1]})
+	}
+	for _, e := range n.edges {
+		e.node.fuzzySearch(target, row, maxEdits, results)
+	}
+}
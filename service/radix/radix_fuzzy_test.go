@@ -0,0 +1,104 @@
+package radix
+
+import (
+	"math/rand"
+	"slices"
+	"sort"
+	"testing"
+)
+
+// 按 rune 计算的编辑距离，作为 FuzzySearch 的对照
+func runeLevenshtein(a, b string) int {
+	x, y := []rune(a), []rune(b)
+	row := make([]int, len(y)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(x); i++ {
+		prev := row[0]
+		row[0] = i
+		for j := 1; j <= len(y); j++ {
+			cost := 1
+			if x[i-1] == y[j-1] {
+				cost = 0
+			}
+			cur := min(row[j]+1, row[j-1]+1, prev+cost)
+			prev, row[j] = row[j], cur
+		}
+	}
+	return row[len(y)]
+}
+
+// 随机键及查询，FuzzySearch 返回的正好是编辑距离不超过 maxEdits 的全部键，距离正确且有序
+func TestFuzzySearchDistanceBounds(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	tree := New[int](nil)
+	m := make(map[string]int)
+	for i := 1; i <= 3000; i++ {
+		k := randomTreeKey(r)
+		tree.Insert(k, i)
+		m[k] = i
+	}
+
+	for i := 0; i < 100; i++ {
+		q := randomTreeKey(r)
+		for max_edits := -1; max_edits <= 3; max_edits++ {
+			want := make([]string, 0)
+			for k := range m {
+				if runeLevenshtein(k, q) <= max_edits {
+					want = append(want, k)
+				}
+			}
+
+			got := tree.FuzzySearch(q, max_edits)
+			if len(got) != len(want) {
+				t.Fatalf("FuzzySearch(%q, %d) returned %d keys, want %d", q, max_edits, len(got), len(want))
+			}
+			for j, fm := range got {
+				if d := runeLevenshtein(fm.Key, q); fm.Distance != d || d > max_edits {
+					t.Fatalf("FuzzySearch(%q, %d): %q distance %d, want %d", q, max_edits, fm.Key, fm.Distance, d)
+				}
+				if fm.Value != m[fm.Key] {
+					t.Fatalf("FuzzySearch(%q, %d): %q value %d, want %d", q, max_edits, fm.Key, fm.Value, m[fm.Key])
+				}
+				if j > 0 && (got[j-1].Distance > fm.Distance || got[j-1].Distance == fm.Distance && got[j-1].Key >= fm.Key) {
+					t.Fatalf("FuzzySearch(%q, %d) is not sorted at %d", q, max_edits, j)
+				}
+			}
+		}
+	}
+}
+
+// 编辑距离按 rune 而不是字节计算：替换一个汉字的距离为1
+func TestFuzzySearchCountsRunes(t *testing.T) {
+	tree := New[int](nil)
+	for i, k := range []string{"哆啦A梦", "哆啦B梦", "哆啦", "中国银行", "中国"} {
+		tree.Insert(k, i)
+	}
+
+	keys := func(matches []FuzzyMatch[int]) []string {
+		results := make([]string, 0, len(matches))
+		for _, fm := range matches {
+			results = append(results, fm.Key)
+		}
+		sort.Strings(results)
+		return results
+	}
+	cases := []struct {
+		query     string
+		max_edits int
+		want      []string
+	}{
+		{"哆啦A梦", 0, []string{"哆啦A梦"}},
+		{"哆啦C梦", 1, []string{"哆啦A梦", "哆啦B梦"}},
+		{"哆啦梦", 1, []string{"哆啦", "哆啦A梦", "哆啦B梦"}},
+		{"中国银行", 1, []string{"中国银行"}},
+		{"中国银", 1, []string{"中国", "中国银行"}},
+		{"丁国", 1, []string{"中国"}},
+	}
+	for _, c := range cases {
+		if got := keys(tree.FuzzySearch(c.query, c.max_edits)); !slices.Equal(got, c.want) {
+			t.Errorf("FuzzySearch(%q, %d) = %q, want %q", c.query, c.max_edits, got, c.want)
+		}
+	}
+}
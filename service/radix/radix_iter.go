@@ -0,0 +1,189 @@
+package radix
+
+// 索引树的有序迭代器：按键的顺序（键为合法 utf8 时即字节序）逐个读取，支持定位、前后移动及范围查询
+// 迭代过程中不能通过 Tree.Insert、Tree.Delete 修改索引树；需要边修改边迭代时，迭代事务提交前的快照
+
+import (
+	"sort"
+)
+
+// 迭代器路径上的一层：i 为 -1 时表示节点自身的叶子，>=0 时表示位于第 i 条边的子树中
+type iterFrame[V any] struct {
+	n *node[V]
+	i int
+}
+
+// Iterator 迭代器的位置在两个键之间：Next 返回位置之后的键并后移，Prev 返回位置之前的键并前移
+type Iterator[V any] struct {
+	root *node[V]
+
+	// 从根节点到位置之后的键所在节点的路径，栈顶为 i == -1 的叶子；栈为空表示位于末尾
+	stack []iterFrame[V]
+}
+
+// Iterator 返回位于第一个键之前的迭代器
+func (t *Tree[V]) Iterator() *Iterator[V] {
+	it := &Iterator[V]{root: t.root}
+	it.Seek("")
+	return it
+}
+
+// Seek 将位置移动到第一个大于等于 key 的键之前
+func (it *Iterator[V]) Seek(key string) {
+	it.stack = it.stack[:0]
+	n := it.root
+	search := key
+	for {
+		it.stack = append(it.stack, iterFrame[V]{n: n, i: -1})
+		if len(search) == 0 {
+			if !n.isLeaf() {
+				it.seekForward(0)
+			}
+			return
+		}
+
+		label := labelOf(search)
+		idx := sort.Search(len(n.edges), func(i int) bool {
+			return n.edges[i].label >= label
+		})
+		if idx == len(n.edges) || n.edges[idx].label != label {
+			it.seekForward(idx)
+			return
+		}
+
+		child := n.edges[idx].node
+		switch {
+		case len(search) >= len(child.prefix) && search[:len(child.prefix)] == child.prefix:
+			it.stack[len(it.stack)-1].i = idx
+			search = search[len(child.prefix):]
+			n = child
+		case child.prefix > search:
+			// 子树中的键都大于 key
+			it.seekForward(idx)
+			return
+		default:
+			// 子树中的键都小于 key
+			it.seekForward(idx + 1)
+			return
+		}
+	}
+}
+
+// SeekEnd 将位置移动到最后一个键之后，之后可以用 Prev 倒序迭代
+func (it *Iterator[V]) SeekEnd() {
+	it.stack = it.stack[:0]
+}
+
+// Next 返回位置之后的键及值，并将位置后移；已到末尾时返回 false
+func (it *Iterator[V]) Next() (string, V, bool) {
+	if len(it.stack) == 0 {
+		var zero V
+		return "", zero, false
+	}
+	leaf := it.stack[len(it.stack)-1].n.leaf
+	it.seekForward(0)
+	return leaf.key, leaf.val, true
+}
+
+// Prev 返回位置之前的键及值，并将位置前移；已到开头时返回 false
+func (it *Iterator[V]) Prev() (string, V, bool) {
+	var zero V
+	if len(it.stack) == 0 {
+		if !it.pushMax(it.root) {
+			return "", zero, false
+		}
+		leaf := it.stack[len(it.stack)-1].n.leaf
+		return leaf.key, leaf.val, true
+	}
+
+	// 位置之后的键是栈顶节点子树中的第一个键，前一个键在栈顶之前的路径上；没有时保持原位置
+	saved := append([]iterFrame[V]{}, it.stack...)
+	it.stack = it.stack[:len(it.stack)-1]
+	for len(it.stack) > 0 {
+		f := &it.stack[len(it.stack)-1]
+		if f.i > 0 {
+			f.i--
+			if it.pushMax(f.n.edges[f.i].node) {
+				leaf := it.stack[len(it.stack)-1].n.leaf
+				return leaf.key, leaf.val, true
+			}
+			continue
+		}
+		if f.i == 0 && f.n.isLeaf() {
+			f.i = -1
+			return f.n.leaf.key, f.n.leaf.val, true
+		}
+		it.stack = it.stack[:len(it.stack)-1]
+	}
+	it.stack = saved
+	return "", zero, false
+}
+
+// 从栈顶节点的第 start 条边开始寻找下一个键，子树中没有时出栈向上寻找
+func (it *Iterator[V]) seekForward(start int) {
+	for len(it.stack) > 0 {
+		f := &it.stack[len(it.stack)-1]
+		if start < len(f.n.edges) {
+			f.i = start
+			if it.pushMin(f.n.edges[start].node) {
+				return
+			}
+			start++
+			continue
+		}
+		it.stack = it.stack[:len(it.stack)-1]
+		if len(it.stack) > 0 {
+			start = it.stack[len(it.stack)-1].i + 1
+		}
+	}
+}
+
+// 将子树中最小的键的路径入栈
+func (it *Iterator[V]) pushMin(n *node[V]) bool {
+	base := len(it.stack)
+	for {
+		if n.isLeaf() {
+			it.stack = append(it.stack, iterFrame[V]{n: n, i: -1})
+			return true
+		}
+		if len(n.edges) == 0 {
+			it.stack = it.stack[:base]
+			return false
+		}
+		it.stack = append(it.stack, iterFrame[V]{n: n, i: 0})
+		n = n.edges[0].node
+	}
+}
+
+// 将子树中最大的键的路径入栈
+func (it *Iterator[V]) pushMax(n *node[V]) bool {
+	base := len(it.stack)
+	for {
+		if num := len(n.edges); num > 0 {
+			it.stack = append(it.stack, iterFrame[V]{n: n, i: num - 1})
+			n = n.edges[num-1].node
+			continue
+		}
+		if n.isLeaf() {
+			it.stack = append(it.stack, iterFrame[V]{n: n, i: -1})
+			return true
+		}
+		it.stack = it.stack[:base]
+		return false
+	}
+}
+
+// WalkRange 按顺序遍历 [start, end) 范围内的键，end 为空时不限制上界
+func (t *Tree[V]) WalkRange(start string, end string, fn WalkFn[V]) {
+	it := &Iterator[V]{root: t.root}
+	it.Seek(start)
+	for {
+		k, v, ok := it.Next()
+		if !ok || (end != "" && k >= end) {
+			return
+		}
+		if fn(k, v) {
+			return
+		}
+	}
+}
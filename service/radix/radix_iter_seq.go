@@ -0,0 +1,49 @@
+//go:build go1.23
+
+package radix
+
+// 索引树的 range-over-func 迭代，需要 Go 1.23 及以上
+
+import (
+	"iter"
+)
+
+// All 按顺序迭代全部键
+func (t *Tree[V]) All() iter.Seq2[string, V] {
+	return t.Range("", "")
+}
+
+// Range 按顺序迭代 [start, end) 范围内的键，end 为空时不限制上界
+func (t *Tree[V]) Range(start string, end string) iter.Seq2[string, V] {
+	return func(yield func(string, V) bool) {
+		t.WalkRange(start, end, func(k string, v V) bool {
+			return !yield(k, v)
+		})
+	}
+}
+
+// Backward 按倒序迭代全部键
+func (t *Tree[V]) Backward() iter.Seq2[string, V] {
+	return func(yield func(string, V) bool) {
+		it := t.Iterator()
+		it.SeekEnd()
+		for {
+			k, v, ok := it.Prev()
+			if !ok || !yield(k, v) {
+				return
+			}
+		}
+	}
+}
+
+// Seq2 从迭代器的当前位置按顺序迭代到末尾
+func (it *Iterator[V]) Seq2() iter.Seq2[string, V] {
+	return func(yield func(string, V) bool) {
+		for {
+			k, v, ok := it.Next()
+			if !ok || !yield(k, v) {
+				return
+			}
+		}
+	}
+}
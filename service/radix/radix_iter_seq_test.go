@@ -0,0 +1,69 @@
+//go:build go1.23
+
+package radix
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// All、Range、Backward 与有序键列表一致，提前 break 时不再产生键
+func TestTreeSeq(t *testing.T) {
+	r := rand.New(rand.NewSource(13))
+	tree := New[int](nil)
+	m := make(map[string]int)
+	for i := 1; i <= 1000; i++ {
+		k := randomTreeKey(r)
+		tree.Insert(k, i)
+		m[k] = i
+	}
+	keys := mapPrefixKeys(m, "")
+
+	collect := func(seq func(func(string, int) bool)) []string {
+		got := make([]string, 0)
+		for k, v := range seq {
+			if v != m[k] {
+				t.Fatalf("%q = %d, want %d", k, v, m[k])
+			}
+			got = append(got, k)
+		}
+		return got
+	}
+
+	if got := collect(tree.All()); !slices.Equal(got, keys) {
+		t.Fatalf("All() returned %d keys, want %d", len(got), len(keys))
+	}
+	backward := slices.Clone(keys)
+	slices.Reverse(backward)
+	if got := collect(tree.Backward()); !slices.Equal(got, backward) {
+		t.Fatalf("Backward() returned %d keys, want %d", len(got), len(keys))
+	}
+
+	for i := 0; i < 100; i++ {
+		start, end := randomTreeKey(r), randomTreeKey(r)
+		if i%10 == 0 {
+			end = ""
+		}
+		want := make([]string, 0)
+		for _, k := range keys {
+			if k >= start && (end == "" || k < end) {
+				want = append(want, k)
+			}
+		}
+		if got := collect(tree.Range(start, end)); !slices.Equal(got, want) {
+			t.Fatalf("Range(%q, %q) = %q, want %q", start, end, got, want)
+		}
+	}
+
+	count := 0
+	for range tree.All() {
+		count++
+		if count == 3 {
+			break
+		}
+	}
+	if count != 3 {
+		t.Fatalf("All() yielded %d keys after break", count)
+	}
+}
@@ -0,0 +1,175 @@
+package radix
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// 迭代器的对照：pos 为位置之后的第一个键在有序键列表中的下标
+type iterModel struct {
+	keys []string
+	pos  int
+}
+
+func (m *iterModel) next() (string, bool) {
+	if m.pos >= len(m.keys) {
+		return "", false
+	}
+	m.pos++
+	return m.keys[m.pos-1], true
+}
+
+func (m *iterModel) prev() (string, bool) {
+	if m.pos <= 0 {
+		return "", false
+	}
+	m.pos--
+	return m.keys[m.pos], true
+}
+
+// 随机定位及前后移动，迭代器与有序键列表一致
+func TestIteratorMatchesSortedKeys(t *testing.T) {
+	r := rand.New(rand.NewSource(11))
+	tree := New[int](nil)
+	m := make(map[string]int)
+	for i := 1; i <= 2000; i++ {
+		k := randomTreeKey(r)
+		tree.Insert(k, i)
+		m[k] = i
+	}
+	keys := mapPrefixKeys(m, "")
+
+	// 定位到空串、第一个键之前、最后一个键之后、已有的键及随机的键
+	targets := []string{"", "\x00", keys[0], keys[len(keys)-1], keys[len(keys)-1] + "\x00", "\xff"}
+	for i := 0; i < 200; i++ {
+		targets = append(targets, randomTreeKey(r), keys[r.Intn(len(keys))])
+	}
+
+	it := tree.Iterator()
+	for _, target := range targets {
+		model := &iterModel{keys: keys, pos: sort.SearchStrings(keys, target)}
+		it.Seek(target)
+		if target == "\xff" {
+			model.pos = len(keys)
+			it.SeekEnd()
+		}
+		for step := 0; step < 20; step++ {
+			var k, want string
+			var ok, want_ok bool
+			var v int
+			op := "Next"
+			if r.Intn(3) == 0 {
+				op = "Prev"
+				k, v, ok = it.Prev()
+				want, want_ok = model.prev()
+			} else {
+				k, v, ok = it.Next()
+				want, want_ok = model.next()
+			}
+			if ok != want_ok || k != want || (ok && v != m[k]) {
+				t.Fatalf("Seek(%q) step %d: %s() = %q, %v, want %q, %v", target, step, op, k, ok, want, want_ok)
+			}
+		}
+	}
+}
+
+func TestIteratorBoundaries(t *testing.T) {
+	empty := New[int](nil).Iterator()
+	if _, _, ok := empty.Next(); ok {
+		t.Error("Next on an empty tree returned a key")
+	}
+	empty.SeekEnd()
+	if _, _, ok := empty.Prev(); ok {
+		t.Error("Prev on an empty tree returned a key")
+	}
+	empty.Seek("中国")
+	if _, _, ok := empty.Next(); ok {
+		t.Error("Seek then Next on an empty tree returned a key")
+	}
+
+	tree := New[int](nil)
+	for i, k := range []string{"中", "中国", "中国银行", "哆啦A梦", "dlam"} {
+		tree.Insert(k, i)
+	}
+	it := tree.Iterator()
+	next := func() string {
+		k, _, ok := it.Next()
+		if !ok {
+			return "<end>"
+		}
+		return k
+	}
+	prev := func() string {
+		k, _, ok := it.Prev()
+		if !ok {
+			return "<begin>"
+		}
+		return k
+	}
+
+	cases := []struct {
+		seek string
+		next string
+		prev string
+	}{
+		{"", "dlam", "<begin>"},
+		{"dlam", "dlam", "<begin>"},
+		{"e", "中", "dlam"},
+		{"中", "中", "dlam"},
+		{"中国", "中国", "中"},
+		{"中国人", "中国银行", "中国"},
+		{"中国银行", "中国银行", "中国"},
+		{"中国银行大厦", "哆啦A梦", "中国银行"},
+		{"哆啦A梦", "哆啦A梦", "中国银行"},
+		{"哆啦B梦", "<end>", "哆啦A梦"},
+	}
+	for _, c := range cases {
+		it.Seek(c.seek)
+		if got := next(); got != c.next {
+			t.Errorf("Seek(%q) Next() = %s, want %s", c.seek, got, c.next)
+		}
+		it.Seek(c.seek)
+		if got := prev(); got != c.prev {
+			t.Errorf("Seek(%q) Prev() = %s, want %s", c.seek, got, c.prev)
+		}
+	}
+
+	// 到末尾后 Prev 返回最后一个键，到开头后 Next 返回第一个键
+	it.SeekEnd()
+	if got := next(); got != "<end>" {
+		t.Errorf("SeekEnd Next() = %s", got)
+	}
+	if got := prev(); got != "哆啦A梦" {
+		t.Errorf("SeekEnd Prev() = %s, want 哆啦A梦", got)
+	}
+	it.Seek("")
+	if got := prev(); got != "<begin>" {
+		t.Errorf("Seek(\"\") Prev() = %s", got)
+	}
+	if got := next(); got != "dlam" {
+		t.Errorf("Next() at the beginning = %s, want dlam", got)
+	}
+
+	ranges := []struct {
+		start, end string
+		want       []string
+	}{
+		{"", "", []string{"dlam", "中", "中国", "中国银行", "哆啦A梦"}},
+		{"中", "中国银行", []string{"中", "中国"}},
+		{"中国人", "哆", []string{"中国银行"}},
+		{"哆啦B梦", "", []string{}},
+		{"中国", "中国", []string{}},
+	}
+	for _, c := range ranges {
+		got := make([]string, 0)
+		tree.WalkRange(c.start, c.end, func(k string, v int) bool {
+			got = append(got, k)
+			return false
+		})
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("WalkRange(%q, %q) = %q, want %q", c.start, c.end, got, c.want)
+		}
+	}
+}
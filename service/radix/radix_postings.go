@@ -0,0 +1,58 @@
+package radix
+
+import (
+	"sort"
+)
+
+// Postings 按升序排列、不重复的字典词ID列表，作为索引树叶子节点的值
+type Postings []uint32
+
+// Contains 二分查找是否包含某个ID
+func (p Postings) Contains(v uint32) bool {
+	idx := sort.Search(len(p), func(i int) bool {
+		return p[i] >= v
+	})
+	return idx < len(p) && p[idx] == v
+}
+
+// Add 按顺序插入一个ID，已存在时不重复添加
+func (p Postings) Add(v uint32) Postings {
+	idx := sort.Search(len(p), func(i int) bool {
+		return p[i] >= v
+	})
+	if idx < len(p) && p[idx] == v {
+		return p
+	}
+	p = append(p, 0)
+	copy(p[idx+1:], p[idx:])
+	p[idx] = v
+	return p
+}
+
+// MergePostings 合并两个有序ID列表，作为索引树的 MergeFn；总是返回新的列表，old 可能被事务之外的快照共享
+func MergePostings(old Postings, new Postings) Postings {
+	merged := make(Postings, 0, len(old)+len(new))
+	i, j := 0, 0
+	for i < len(old) && j < len(new) {
+		switch {
+		case old[i] < new[j]:
+			merged = append(merged, old[i])
+			i++
+		case old[i] > new[j]:
+			merged = append(merged, new[j])
+			j++
+		default:
+			merged = append(merged, old[i])
+			i++
+			j++
+		}
+	}
+	merged = append(merged, old[i:]...)
+	merged = append(merged, new[j:]...)
+	return merged
+}
+
+// NewPostingTree 返回叶子节点保存字典词ID列表的索引树，同一个键多次插入时合并ID
+func NewPostingTree() *Tree[Postings] {
+	return New(MergePostings)
+}
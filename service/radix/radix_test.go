@@ -0,0 +1,180 @@
+package radix
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// 随机键的字符：中、丁、一、七、万 的 utf8 编码都以 0xE4 0xB8 开头，哆、啦 以 0xE5 开头，另有 ascii 字符
+var treeTestRunes = []rune("中丁一七万哆啦梦国银行aAb1")
+
+func randomTreeKey(r *rand.Rand) string {
+	var b strings.Builder
+	n := 1 + r.Intn(5)
+	for i := 0; i < n; i++ {
+		b.WriteRune(treeTestRunes[r.Intn(len(treeTestRunes))])
+	}
+	return b.String()
+}
+
+// 按 map 计算前缀下的全部键，已排序
+func mapPrefixKeys(m map[string]int, prefix string) []string {
+	keys := make([]string, 0)
+	for k := range m {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// 按 map 计算 s 的最长前缀键
+func mapLongestPrefix(m map[string]int, s string) (string, bool) {
+	best, found := "", false
+	for k := range m {
+		if strings.HasPrefix(s, k) && (!found || len(k) > len(best)) {
+			best, found = k, true
+		}
+	}
+	return best, found
+}
+
+func checkTreePrefixes[V any](t *testing.T, n *node[V]) {
+	t.Helper()
+	if !utf8.ValidString(n.prefix) {
+		t.Fatalf("node prefix %q splits a rune", n.prefix)
+	}
+	for _, e := range n.edges {
+		if label := labelOf(e.node.prefix); label != e.label {
+			t.Fatalf("edge label %q does not match prefix %q", e.label, e.node.prefix)
+		}
+		checkTreePrefixes(t, e.node)
+	}
+}
+
+func checkTreeAgainstMap(t *testing.T, r *rand.Rand, tree *Tree[int], m map[string]int) {
+	t.Helper()
+	if tree.Len() != len(m) {
+		t.Fatalf("Len() = %d, want %d", tree.Len(), len(m))
+	}
+	for k, v := range m {
+		if got, ok := tree.Get(k); !ok || got != v {
+			t.Fatalf("Get(%q) = %d, %v, want %d", k, got, ok, v)
+		}
+	}
+	checkTreePrefixes(t, tree.root)
+
+	for i := 0; i < 50; i++ {
+		q := randomTreeKey(r)
+		if got, ok := tree.Get(q); ok != (m[q] != 0) || got != m[q] {
+			t.Fatalf("Get(%q) = %d, %v, want %d", q, got, ok, m[q])
+		}
+
+		runes := []rune(q)
+		prefix := string(runes[:r.Intn(len(runes))])
+		got := make([]string, 0)
+		tree.WalkPrefix(prefix, func(k string, v int) bool {
+			got = append(got, k)
+			return false
+		})
+		if want := mapPrefixKeys(m, prefix); !reflect.DeepEqual(got, want) {
+			t.Fatalf("WalkPrefix(%q) = %q, want %q", prefix, got, want)
+		}
+
+		key, val, ok := tree.LongestPrefix(q)
+		want, want_ok := mapLongestPrefix(m, q)
+		if ok != want_ok || key != want || (ok && val != m[want]) {
+			t.Fatalf("LongestPrefix(%q) = %q, %v, want %q, %v", q, key, ok, want, want_ok)
+		}
+	}
+
+	all := make([]string, 0, len(m))
+	tree.Walk(func(k string, v int) bool {
+		all = append(all, k)
+		return false
+	})
+	if want := mapPrefixKeys(m, ""); !reflect.DeepEqual(all, want) {
+		t.Fatalf("Walk() = %d keys in order %q, want %q", len(all), all, want)
+	}
+}
+
+// 随机插入、删除汉字及混合键，树的行为与 map 一致
+func TestTreeMatchesMap(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	tree := New[int](nil)
+	m := make(map[string]int)
+	for i := 1; i <= 5000; i++ {
+		k := randomTreeKey(r)
+		if r.Intn(4) == 0 {
+			_, ok := tree.Delete(k)
+			if _, exists := m[k]; ok != exists {
+				t.Fatalf("Delete(%q) = %v, want %v", k, ok, exists)
+			}
+			delete(m, k)
+		} else {
+			updated, _ := tree.Insert(k, i)
+			if _, exists := m[k]; updated != exists {
+				t.Fatalf("Insert(%q) updated = %v, want %v", k, updated, exists)
+			}
+			m[k] = i
+		}
+		if i%500 == 0 {
+			checkTreeAgainstMap(t, r, tree, m)
+		}
+	}
+}
+
+// 首字节相同而 rune 不同的键分属不同的边，不会在字符中间分叉
+func TestTreeSharedLeadingByte(t *testing.T) {
+	tree := New[int](nil)
+	keys := map[string]int{"中国": 1, "丁香": 2, "一": 3, "七": 4, "中": 5}
+	for k, v := range keys {
+		tree.Insert(k, v)
+	}
+	checkTreePrefixes(t, tree.root)
+
+	for k, v := range keys {
+		if got, ok := tree.Get(k); !ok || got != v {
+			t.Errorf("Get(%q) = %d, %v, want %d", k, got, ok, v)
+		}
+	}
+	// 只有首字节或前两个字节时不能匹配任何键
+	for _, partial := range []string{"\xe4", "\xe4\xb8", "中\xe4\xb8"} {
+		if _, ok := tree.Get(partial); ok {
+			t.Errorf("Get(%q) matched a partial rune", partial)
+		}
+		if key, _, ok := tree.LongestPrefix(partial); ok && key != "中" {
+			t.Errorf("LongestPrefix(%q) = %q", partial, key)
+		}
+	}
+	if key, v, ok := tree.LongestPrefix("丁香花"); !ok || key != "丁香" || v != 2 {
+		t.Errorf("LongestPrefix(丁香花) = %q, %d, %v", key, v, ok)
+	}
+	if key, _, ok := tree.LongestPrefix("丁"); ok {
+		t.Errorf("LongestPrefix(丁) = %q, want none", key)
+	}
+
+	got := make([]string, 0)
+	tree.WalkPrefix("中", func(k string, v int) bool {
+		got = append(got, k)
+		return false
+	})
+	if want := []string{"中", "中国"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("WalkPrefix(中) = %q, want %q", got, want)
+	}
+
+	if _, ok := tree.Delete("丁香"); !ok {
+		t.Fatal("Delete(丁香) failed")
+	}
+	checkTreePrefixes(t, tree.root)
+	for _, k := range []string{"一", "七", "中", "中国"} {
+		if _, ok := tree.Get(k); !ok {
+			t.Errorf("Get(%q) failed after deleting 丁香", k)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package radix
+
+// 子树 top-k 缓存：每个节点缓存其子树中权重最大的 k 个键，按前缀查询权重最大的补全时只需定位到前缀节点，不必遍历子树
+// 插入时沿路径逐个节点合并新键；删除或权重变小时，仅对缓存中包含该键的节点用其叶子及子节点的缓存重新计算
+
+import (
+	"sort"
+	"strings"
+)
+
+// WeightFn 叶子节点的权重，返回 <=0 时该键不进入 top-k 缓存
+type WeightFn[V any] func(key string, v V) int
+
+// WeightedKey 按权重查询返回的键、值及权重
+type WeightedKey[V any] struct {
+	Key    string
+	Value  V
+	Weight int
+}
+
+// 节点缓存中的一项
+type topEntry[V any] struct {
+	leaf   *leafNode[V]
+	weight int
+}
+
+// 缓存按权重从大到小排序，权重相同时按键排序
+func topLess[V any](a, b topEntry[V]) bool {
+	if a.weight != b.weight {
+		return a.weight > b.weight
+	}
+	return a.leaf.key < b.leaf.key
+}
+
+/**
+ * EnableTopK 为每个节点建立子树中权重最大的 k 个键的缓存，之后的插入、删除及事务都会维护缓存
+ * 会原地修改全部节点，须在索引树发布给其他协程之前调用
+ */
+func (t *Tree[V]) EnableTopK(k int, weigh WeightFn[V]) {
+	if k <= 0 || weigh == nil {
+		t.topK, t.weigh = 0, nil
+		clearTop(t.root)
+		return
+	}
+	t.topK, t.weigh = k, weigh
+	buildTop(t.root, k, weigh)
+}
+
+func clearTop[V any](n *node[V]) {
+	n.top = nil
+	for _, e := range n.edges {
+		clearTop(e.node)
+	}
+}
+
+// 后序遍历，子节点的缓存先建立
+func buildTop[V any](n *node[V], k int, weigh WeightFn[V]) {
+	for _, e := range n.edges {
+		buildTop(e.node, k, weigh)
+	}
+	n.top = computeTop(n, k, weigh)
+}
+
+// 用节点自身的叶子及各子节点的缓存计算节点的缓存
+func computeTop[V any](n *node[V], k int, weigh WeightFn[V]) []topEntry[V] {
+	merged := make([]topEntry[V], 0, k+1)
+	if n.leaf != nil {
+		if w := weigh(n.leaf.key, n.leaf.val); w > 0 {
+			merged = append(merged, topEntry[V]{leaf: n.leaf, weight: w})
+		}
+	}
+	for _, e := range n.edges {
+		merged = append(merged, e.node.top...)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return topLess(merged[i], merged[j])
+	})
+	if len(merged) > k {
+		merged = merged[:k:k]
+	}
+	if len(merged) == 0 {
+		return nil
+	}
+	return merged
+}
+
+// 缓存中键的位置，不存在时返回 -1
+func topIndex[V any](top []topEntry[V], key string) int {
+	for i, te := range top {
+		if te.leaf.key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+/**
+ * 将插入或更新后的叶子合并到节点的缓存，总是返回新的切片，原缓存可能被其他快照共享
+ * @return bool 是否需要重新计算：缓存已满且该键的权重变小或不再进入缓存时，缓存之外的键可能进入前 k 个
+ */
+func topUpsert[V any](top []topEntry[V], leaf *leafNode[V], weight int, k int) ([]topEntry[V], bool) {
+	idx := topIndex(top, leaf.key)
+	if idx >= 0 && weight < top[idx].weight && len(top) >= k {
+		return top, true
+	}
+	if idx < 0 && (weight <= 0 || (len(top) >= k && !topLess(topEntry[V]{leaf: leaf, weight: weight}, top[len(top)-1]))) {
+		return top, false
+	}
+
+	updated := make([]topEntry[V], 0, len(top)+1)
+	for i, te := range top {
+		if i != idx {
+			updated = append(updated, te)
+		}
+	}
+	if weight > 0 {
+		entry := topEntry[V]{leaf: leaf, weight: weight}
+		pos := sort.Search(len(updated), func(i int) bool {
+			return topLess(entry, updated[i])
+		})
+		updated = append(updated, topEntry[V]{})
+		copy(updated[pos+1:], updated[pos:])
+		updated[pos] = entry
+	}
+	if len(updated) > k {
+		updated = updated[:k:k]
+	}
+	if len(updated) == 0 {
+		return nil, false
+	}
+	return updated, false
+}
+
+// 插入或更新叶子后，自下而上更新路径上各节点的缓存
+func (t *Tree[V]) topInsert(path []*node[V], leaf *leafNode[V]) {
+	if t.topK <= 0 {
+		return
+	}
+	weight := t.weigh(leaf.key, leaf.val)
+	for i := len(path) - 1; i >= 0; i-- {
+		top, recompute := topUpsert(path[i].top, leaf, weight, t.topK)
+		if recompute {
+			top = computeTop(path[i], t.topK, t.weigh)
+		}
+		path[i].top = top
+	}
+}
+
+// 删除键后，自下而上重新计算路径上缓存中包含该键的节点
+func (t *Tree[V]) topDelete(path []*node[V], key string) {
+	if t.topK <= 0 {
+		return
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		if topIndex(path[i].top, key) >= 0 {
+			path[i].top = computeTop(path[i], t.topK, t.weigh)
+		}
+	}
+}
+
+/**
+ * TopKPrefix 前缀下权重最大的 k 个键，按权重从大到小排序
+ * 已通过 EnableTopK 建立缓存且 k 不超过缓存大小时，耗时只与前缀长度及 k 有关；否则遍历前缀下的子树
+ */
+func (t *Tree[V]) TopKPrefix(prefix string, k int) []WeightedKey[V] {
+	results := make([]WeightedKey[V], 0)
+	if k <= 0 {
+		return results
+	}
+	if t.topK <= 0 || k > t.topK {
+		return t.topKWalk(prefix, k)
+	}
+
+	n := t.root
+	search := prefix
+	for len(search) > 0 {
+		n = n.getEdge(labelOf(search))
+		if n == nil {
+			return results
+		}
+		if strings.HasPrefix(search, n.prefix) {
+			search = search[len(n.prefix):]
+			continue
+		}
+		// 节点的前缀比剩余的查询长，节点的子树即为前缀下的全部键
+		if !strings.HasPrefix(n.prefix, search) {
+			return results
+		}
+		break
+	}
+	for _, te := range n.top {
+		if len(results) >= k {
+			break
+		}
+		results = append(results, WeightedKey[V]{Key: te.leaf.key, Value: te.leaf.val, Weight: te.weight})
+	}
+	return results
+}
+
+// 没有缓存时遍历前缀下的子树
+func (t *Tree[V]) topKWalk(prefix string, k int) []WeightedKey[V] {
+	weigh := t.weigh
+	if weigh == nil {
+		weigh = func(string, V) int { return 1 }
+	}
+	results := make([]WeightedKey[V], 0)
+	t.WalkPrefix(prefix, func(key string, v V) bool {
+		if w := weigh(key, v); w > 0 {
+			results = append(results, WeightedKey[V]{Key: key, Value: v, Weight: w})
+		}
+		return false
+	})
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Weight != results[j].Weight {
+			return results[i].Weight > results[j].Weight
+		}
+		return results[i].Key < results[j].Key
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
@@ -0,0 +1,135 @@
+package radix
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// 权重为值除以 10，值小于 10 的键不进入缓存
+func topKTestWeight(key string, v int) int {
+	return v / 10
+}
+
+// 按 map 计算前缀下权重最大的 k 个键
+func mapTopKPrefix(m map[string]int, prefix string, k int) []WeightedKey[int] {
+	results := make([]WeightedKey[int], 0)
+	for key, v := range m {
+		if w := topKTestWeight(key, v); w > 0 && strings.HasPrefix(key, prefix) {
+			results = append(results, WeightedKey[int]{Key: key, Value: v, Weight: w})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Weight != results[j].Weight {
+			return results[i].Weight > results[j].Weight
+		}
+		return results[i].Key < results[j].Key
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+
+func checkTopKAgainstMap(t *testing.T, r *rand.Rand, tree *Tree[int], m map[string]int, top_k int) {
+	t.Helper()
+	for i := 0; i < 30; i++ {
+		runes := []rune(randomTreeKey(r))
+		prefix := string(runes[:r.Intn(len(runes))])
+		// k 超过缓存大小时遍历子树，结果也须一致
+		for _, k := range []int{1, top_k, top_k + 3} {
+			got := tree.TopKPrefix(prefix, k)
+			if want := mapTopKPrefix(m, prefix, k); !reflect.DeepEqual(got, want) {
+				t.Fatalf("TopKPrefix(%q, %d) = %v, want %v", prefix, k, got, want)
+			}
+		}
+	}
+}
+
+// 随机插入、更新（权重可能变小）、删除及按前缀删除后，缓存给出的结果与遍历 map 相同
+func TestTopKPrefixAfterDelete(t *testing.T) {
+	const top_k = 5
+	r := rand.New(rand.NewSource(17))
+	tree := New[int](nil)
+	m := make(map[string]int)
+	for i := 0; i < 500; i++ {
+		k := randomTreeKey(r)
+		v := r.Intn(1000)
+		tree.Insert(k, v)
+		m[k] = v
+	}
+	tree.EnableTopK(top_k, topKTestWeight)
+	checkTopKAgainstMap(t, r, tree, m, top_k)
+
+	for i := 1; i <= 3000; i++ {
+		k := randomTreeKey(r)
+		switch op := r.Intn(10); {
+		case op < 4:
+			tree.Delete(k)
+			delete(m, k)
+		case op == 4:
+			prefix := string([]rune(k)[:1])
+			tree.DeletePrefix(prefix)
+			for key := range m {
+				if strings.HasPrefix(key, prefix) {
+					delete(m, key)
+				}
+			}
+		default:
+			v := r.Intn(1000)
+			tree.Insert(k, v)
+			m[k] = v
+		}
+		if i%100 == 0 {
+			checkTopKAgainstMap(t, r, tree, m, top_k)
+		}
+	}
+	checkTopKAgainstMap(t, r, tree, m, top_k)
+}
+
+// 事务中删除缓存里的键，提交后的树及原树的结果都正确
+func TestTopKPrefixAfterTxnDelete(t *testing.T) {
+	const top_k = 3
+	r := rand.New(rand.NewSource(19))
+	tree := New[int](nil)
+	m := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		k := randomTreeKey(r)
+		v := r.Intn(1000)
+		tree.Insert(k, v)
+		m[k] = v
+	}
+	tree.EnableTopK(top_k, topKTestWeight)
+
+	for round := 0; round < 5; round++ {
+		next := make(map[string]int, len(m))
+		for k, v := range m {
+			next[k] = v
+		}
+		txn := tree.Txn()
+		// 删除根节点缓存中的全部键，迫使缓存之外的键进入前 k 个
+		for _, wk := range tree.TopKPrefix("", top_k) {
+			txn.Delete(wk.Key)
+			delete(next, wk.Key)
+		}
+		for i := 0; i < 200; i++ {
+			k := randomTreeKey(r)
+			if r.Intn(2) == 0 {
+				txn.Delete(k)
+				delete(next, k)
+			} else {
+				v := r.Intn(1000)
+				if _, err := txn.Insert(k, v); err != nil {
+					t.Fatal(err)
+				}
+				next[k] = v
+			}
+		}
+		committed := txn.Commit()
+		checkTopKAgainstMap(t, r, tree, m, top_k)
+		checkTopKAgainstMap(t, r, committed, next, top_k)
+		tree, m = committed, next
+	}
+}
@@ -1,8 +1,24 @@
 package radix
 
+// 运行时的索引节点树：由 str_radix_nodes 构建，第一层节点的 key 为索引词的前两个字符（或前两个音节），之后每层一个字符（或音节）
+// 匹配时按子节点 key 的最短、最长 rune 数决定每层尝试截取的长度，找出查询中出现的全部索引词
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
 type RadixNode struct {
 	ID           int
 	Weight       int
+	IndexID      int   // 节点对应的索引词ID，中间节点为0
+	IndexIDs     []int // 文字相同的其他索引词ID，来自 node_index_ids
 	Children     map[string]*RadixNode
 	chileRuneMin int
 	childRuneMax int
@@ -28,6 +44,14 @@ func (rn *RadixNode) calc_child_rune_length() {
 	rn.childRuneMax = max
 }
 
+// 节点上的全部索引词ID
+func (rn *RadixNode) index_ids() []int {
+	if rn.IndexID == 0 {
+		return rn.IndexIDs
+	}
+	return append([]int{rn.IndexID}, rn.IndexIDs...)
+}
+
 func (rn *RadixNode) GetChildRuneMin() int {
 	if rn.chileRuneMin > 0 {
 		return rn.chileRuneMin
@@ -49,3 +73,183 @@ func (rn *RadixNode) GetChildRuneMax() int {
 	rn.calc_child_rune_length()
 	return rn.childRuneMax
 }
+
+// 查询中匹配到的索引词
+type RadixMatch struct {
+	Word    string `json:"word"`     // 查询中匹配到的部分
+	Start   int    `json:"start"`    // 在查询中的起始 rune 位置
+	End     int    `json:"end"`      // 在查询中的结束 rune 位置（不含）
+	IndexID int    `json:"index_id"` // 索引词ID
+	Weight  int    `json:"weight"`   // 节点层级
+}
+
+/**
+ * 从 str_radix_nodes 构建索引节点树，返回的根节点为虚拟节点，其子节点为第一层（weight 为2）的节点
+ * parent_id 为0的节点（尚未执行 step5 计算父子关系）按 hierarchy_key 去掉 key 推导父节点
+ * 按ID范围分成多个协程并行读取，由当前协程构建
+ */
+func LoadRadixForest(db *sqlx.DB) (*RadixNode, error) {
+	start_time := time.Now().UnixMilli()
+	root := &RadixNode{Children: make(map[string]*RadixNode)}
+
+	table_range := getTableRange(db, "str_radix_nodes", "")
+	if table_range.Count == 0 {
+		return root, nil
+	}
+
+	recordCh := make(chan []StrRadixNode, 100)
+	errCh := make(chan error, 1)
+	var wg sync.WaitGroup
+	for _, wr := range table_range.Split(10000, 0) {
+		wg.Add(1)
+		go func(idrange IDRange) {
+			defer wg.Done()
+			if err := _radix_forest_read_nodes(db, idrange, recordCh); err != nil {
+				select {
+				case errCh <- err:
+				default:
+				}
+			}
+		}(wr)
+	}
+
+	// 等待所有读取协程完成
+	go func() {
+		wg.Wait()
+		close(recordCh)
+	}()
+
+	// 用当前协程收集节点，全部读取后再建立父子关系
+	records := make([]StrRadixNode, 0, table_range.Count)
+	for batch := range recordCh {
+		records = append(records, batch...)
+	}
+	select {
+	case err := <-errCh:
+		return nil, err
+	default:
+	}
+
+	nodes_by_id := make(map[int]*RadixNode, len(records))
+	nodes_by_key := make(map[string]*RadixNode, len(records))
+	for _, r := range records {
+		rn := &RadixNode{ID: r.ID, Weight: r.Weight, IndexID: r.IndexID}
+		nodes_by_id[r.ID] = rn
+		nodes_by_key[r.HierarchyKey] = rn
+	}
+
+	var extras []NodeIndexID
+	if err := db.Select(&extras, "SELECT id, node_id, index_id FROM node_index_ids ORDER BY id"); err != nil {
+		return nil, fmt.Errorf("failed to read node_index_ids: %w", err)
+	}
+	for _, e := range extras {
+		if rn, exists := nodes_by_id[e.NodeID]; exists {
+			rn.IndexIDs = append(rn.IndexIDs, e.IndexID)
+		}
+	}
+
+	orphan_count := 0
+	for _, r := range records {
+		var parent *RadixNode
+		switch {
+		case r.Weight <= 2:
+			parent = root
+		case r.ParentID > 0:
+			parent = nodes_by_id[r.ParentID]
+		default:
+			parent = nodes_by_key[radix_parent_hierarchy_key(r.HierarchyKey, r.Key)]
+		}
+		if parent == nil {
+			orphan_count++
+			continue
+		}
+		if parent.Children == nil {
+			parent.Children = make(map[string]*RadixNode)
+		}
+		parent.Children[r.Key] = nodes_by_id[r.ID]
+	}
+
+	// 预先计算子节点 key 的长度范围，匹配时只读，可以被多个协程同时使用
+	root.calc_child_rune_length()
+	for _, rn := range nodes_by_id {
+		rn.calc_child_rune_length()
+	}
+
+	if orphan_count > 0 {
+		log.Printf("索引节点树中有 %d 个节点找不到父节点", orphan_count)
+	}
+	log.Printf("加载索引节点树：节点 %d 个，第一层节点 %d 个，耗时 %d ms", len(nodes_by_id), len(root.Children), time.Now().UnixMilli()-start_time)
+	return root, nil
+}
+
+// 读取ID范围内的节点；每批次1000个节点，通过通道传递
+func _radix_forest_read_nodes(db *sqlx.DB, idrange IDRange, recordCh chan<- []StrRadixNode) error {
+	range_batch := 1000
+	for i := idrange.MinId; i <= idrange.MaxId; i += range_batch {
+		var records []StrRadixNode
+		err := db.Select(&records, "SELECT id, parent_id, key, hierarchy_key, index_id, weight, child_count FROM str_radix_nodes WHERE id >= ? AND id < ? AND id <= ?", i, i+range_batch, idrange.MaxId)
+		if err != nil {
+			return fmt.Errorf("failed to read str_radix_nodes: %w", err)
+		}
+		if len(records) > 0 {
+			recordCh <- records
+		}
+	}
+	return nil
+}
+
+// 父节点的 hierarchy_key：去掉末尾的 key，多音节的索引词还须去掉音节之间的空格
+func radix_parent_hierarchy_key(hierarchy_key string, key string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(hierarchy_key, key), " ")
+}
+
+/**
+ * 找出查询中出现的全部索引词，rn 须为 LoadRadixForest 返回的根节点
+ * 从查询的每个位置开始逐层向下匹配，每层按子节点 key 的最短、最长 rune 数截取查询；音节之间的空格在每层开始时跳过
+ * 节点上有多个文字相同的索引词时，每个索引词各返回一条
+ * @return []RadixMatch 按起始位置排序，起始位置相同时较长的在前
+ */
+func (rn *RadixNode) Match(query string) []RadixMatch {
+	runes := []rune(strings.ToLower(query))
+	matches := make([]RadixMatch, 0)
+	for start := 0; start < len(runes); start++ {
+		if runes[start] == ' ' {
+			continue
+		}
+		rn.match_from(runes, start, start, &matches)
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Start != matches[j].Start {
+			return matches[i].Start < matches[j].Start
+		}
+		return matches[i].End > matches[j].End
+	})
+	return matches
+}
+
+// 从 pos 开始匹配当前节点的子节点，start 为匹配的起始位置
+func (rn *RadixNode) match_from(runes []rune, start int, pos int, matches *[]RadixMatch) {
+	for pos < len(runes) && runes[pos] == ' ' {
+		pos++
+	}
+	min_len, max_len := rn.GetChildRuneMin(), rn.GetChildRuneMax()
+	if min_len == 0 {
+		return
+	}
+	for l := min(max_len, len(runes)-pos); l >= min_len; l-- {
+		child, exists := rn.Children[string(runes[pos:pos+l])]
+		if !exists {
+			continue
+		}
+		for _, index_id := range child.index_ids() {
+			*matches = append(*matches, RadixMatch{
+				Word:    string(runes[start : pos+l]),
+				Start:   start,
+				End:     pos + l,
+				IndexID: index_id,
+				Weight:  child.Weight,
+			})
+		}
+		child.match_from(runes, start, pos+l, matches)
+	}
+}
@@ -0,0 +1,101 @@
+package radix
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var radixTreeTestDicts = map[string][]string{
+	"places": testPlaces,
+	"toys":   {"哆啦A梦 洗发水", "3D打印机"},
+}
+
+// 索引节点树与 str_radix_nodes 一致：每个节点都在树中，父节点由 hierarchy_key 去掉 key 得到
+func TestLoadRadixForestShape(t *testing.T) {
+	db := open_test_indexdb(t, build_test_index(t, radixTreeTestDicts, nil))
+	var rows []StrRadixNode
+	if err := db.Select(&rows, "SELECT id, parent_id, key, hierarchy_key, index_id, weight, child_count FROM str_radix_nodes"); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) == 0 {
+		t.Fatal("no str_radix_nodes")
+	}
+	ids_by_key := make(map[string]int, len(rows))
+	for _, r := range rows {
+		ids_by_key[r.HierarchyKey] = r.ID
+	}
+	want := make(map[int]string, len(rows))
+	for _, r := range rows {
+		parent_id := 0
+		if r.Weight > 2 {
+			parent_id = ids_by_key[radix_parent_hierarchy_key(r.HierarchyKey, r.Key)]
+		}
+		want[r.ID] = fmt.Sprintf("parent %d key %q weight %d index %d", parent_id, r.Key, r.Weight, r.IndexID)
+	}
+
+	root, err := LoadRadixForest(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[int]string, len(rows))
+	var walk func(parent *RadixNode)
+	walk = func(parent *RadixNode) {
+		for key, rn := range parent.Children {
+			if _, exists := got[rn.ID]; exists {
+				t.Fatalf("node %d appears twice in the forest", rn.ID)
+			}
+			got[rn.ID] = fmt.Sprintf("parent %d key %q weight %d index %d", parent.ID, key, rn.Weight, rn.IndexID)
+			walk(rn)
+		}
+	}
+	walk(root)
+	if !reflect.DeepEqual(got, want) {
+		for id, w := range want {
+			if got[id] != w {
+				t.Errorf("node %d: got %s, want %s", id, got[id], w)
+			}
+		}
+		t.Fatalf("forest has %d nodes, want %d", len(got), len(want))
+	}
+}
+
+// 按子节点 key 的 rune 长度剪枝后，匹配结果与逐个比较全部节点的结果相同
+func TestRadixMatchAgainstBruteForce(t *testing.T) {
+	db := open_test_indexdb(t, build_test_index(t, radixTreeTestDicts, nil))
+	var nodes []StrRadixNode
+	if err := db.Select(&nodes, "SELECT id, parent_id, key, hierarchy_key, index_id, weight, child_count FROM str_radix_nodes WHERE index_id > 0"); err != nil {
+		t.Fatal(err)
+	}
+	root, err := LoadRadixForest(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	queries := []string{"去中国银行大厦", "zhong guo yin hang da sha", "zgyh", "重庆市长沙市", "duo la a meng xi fa shui", "3d打印机", "哆啦a梦洗发水"}
+	for _, n := range nodes {
+		queries = append(queries, n.HierarchyKey)
+	}
+	for _, q := range queries {
+		runes := []rune(strings.ToLower(q))
+		want := make([]string, 0)
+		for start := range runes {
+			for _, n := range nodes {
+				if strings.HasPrefix(string(runes[start:]), n.HierarchyKey) {
+					want = append(want, fmt.Sprintf("%d:%s:%d", start, n.HierarchyKey, n.IndexID))
+				}
+			}
+		}
+		got := make([]string, 0)
+		for _, m := range root.Match(q) {
+			got = append(got, fmt.Sprintf("%d:%s:%d", m.Start, m.Word, m.IndexID))
+		}
+		sort.Strings(want)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Match(%s) = %v, want %v", q, got, want)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+package radix
+
+// 索引树的写时复制（copy-on-write）事务：事务只复制从根节点到被修改节点路径上的节点，未修改的子树与原树共享
+// 提交后得到新的索引树，原树保持不变，正在使用原树的查询无需加锁
+// 已发布（可能被并发读取）的索引树只能通过事务修改，Tree.Insert、Tree.Delete 会原地修改节点
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Txn 索引树上的写事务，不能被多个协程同时使用
+type Txn[V any] struct {
+	root  *node[V]
+	size  int
+	merge MergeFn[V]
+	topK  int
+	weigh WeightFn[V]
+
+	// 本事务中新建或复制的节点，只属于本事务，可以原地修改
+	writable map[*node[V]]struct{}
+}
+
+// Txn 基于当前索引树开始一个事务，事务中的修改不影响当前索引树
+func (t *Tree[V]) Txn() *Txn[V] {
+	return &Txn[V]{
+		root:     t.root,
+		size:     t.size,
+		merge:    t.merge,
+		topK:     t.topK,
+		weigh:    t.weigh,
+		writable: make(map[*node[V]]struct{}),
+	}
+}
+
+// Len 事务中索引树的键数量
+func (txn *Txn[V]) Len() int {
+	return txn.size
+}
+
+// Get 查询事务中索引树的键，可以读到本事务尚未提交的修改
+func (txn *Txn[V]) Get(s string) (V, bool) {
+	return (&Tree[V]{root: txn.root}).Get(s)
+}
+
+// 返回可以原地修改的节点：本事务中已复制过的节点直接返回，否则复制一份
+func (txn *Txn[V]) writeNode(n *node[V]) *node[V] {
+	if _, ok := txn.writable[n]; ok {
+		return n
+	}
+	nc := &node[V]{
+		leaf:   n.leaf,
+		prefix: n.prefix,
+		top:    n.top, // 缓存总是整体替换，可以与原节点共享
+	}
+	if len(n.edges) > 0 {
+		nc.edges = make(edges[V], len(n.edges))
+		copy(nc.edges, n.edges)
+	}
+	txn.writable[nc] = struct{}{}
+	return nc
+}
+
+// Insert 插入或更新一个键，已存在时按索引树的 merge 合并；返回是否更新了已存在的键
+func (txn *Txn[V]) Insert(s string, v V) (bool, error) {
+	root, _, updated, err := txn.insert(txn.root, s, s, v)
+	if err != nil {
+		return false, err
+	}
+	txn.root = root
+	return updated, nil
+}
+
+// 返回复制后的节点及插入或更新后的叶子
+func (txn *Txn[V]) insert(n *node[V], key string, search string, v V) (*node[V], *leafNode[V], bool, error) {
+	// 叶子节点可能被其他快照共享，更新时总是新建叶子节点
+	if len(search) == 0 {
+		nc := txn.writeNode(n)
+		updated := n.isLeaf()
+		val := v
+		if updated && txn.merge != nil {
+			val = txn.merge(n.leaf.val, v)
+		}
+		if !updated {
+			txn.size++
+		}
+		nc.leaf = &leafNode[V]{key: key, val: val}
+		txn.topInsert(nc, nc.leaf)
+		return nc, nc.leaf, updated, nil
+	}
+
+	label := labelOf(search)
+	child := n.getEdge(label)
+	if child == nil {
+		nc := txn.writeNode(n)
+		newLeaf := txn.writeNode(&node[V]{
+			leaf:   &leafNode[V]{key: key, val: v},
+			prefix: search,
+		})
+		nc.addEdge(edge[V]{label: label, node: newLeaf})
+		txn.size++
+		txn.topInsert(newLeaf, newLeaf.leaf)
+		txn.topInsert(nc, newLeaf.leaf)
+		return nc, newLeaf.leaf, false, nil
+	}
+
+	commonPrefix := longestPrefix(search, child.prefix)
+	if commonPrefix == len(child.prefix) {
+		newChild, leaf, updated, err := txn.insert(child, key, search[commonPrefix:], v)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		nc := txn.writeNode(n)
+		if err := nc.updateEdge(label, newChild); err != nil {
+			return nil, nil, false, err
+		}
+		txn.topInsert(nc, leaf)
+		return nc, leaf, updated, nil
+	}
+
+	// 分裂节点：新建公共前缀节点，原子节点复制后截去公共前缀
+	nc := txn.writeNode(n)
+	split := txn.writeNode(&node[V]{prefix: search[:commonPrefix]})
+	if err := nc.updateEdge(label, split); err != nil {
+		return nil, nil, false, err
+	}
+	modChild := txn.writeNode(child)
+	modChild.prefix = child.prefix[commonPrefix:]
+	split.addEdge(edge[V]{label: labelOf(modChild.prefix), node: modChild})
+
+	txn.size++
+	leaf := &leafNode[V]{key: key, val: v}
+	search = search[commonPrefix:]
+	if len(search) == 0 {
+		split.leaf = leaf
+	} else {
+		newLeaf := txn.writeNode(&node[V]{leaf: leaf, prefix: search})
+		txn.topInsert(newLeaf, leaf)
+		split.addEdge(edge[V]{label: labelOf(search), node: newLeaf})
+	}
+	if txn.topK > 0 {
+		split.top = computeTop(split, txn.topK, txn.weigh)
+	}
+	txn.topInsert(nc, leaf)
+	return nc, leaf, false, nil
+}
+
+// 更新本事务中节点的 top-k 缓存，leaf 为插入或更新后的叶子
+func (txn *Txn[V]) topInsert(nc *node[V], leaf *leafNode[V]) {
+	if txn.topK <= 0 {
+		return
+	}
+	top, recompute := topUpsert(nc.top, leaf, txn.weigh(leaf.key, leaf.val), txn.topK)
+	if recompute {
+		top = computeTop(nc, txn.topK, txn.weigh)
+	}
+	nc.top = top
+}
+
+// Delete 删除一个键，返回原来的值及是否删除
+func (txn *Txn[V]) Delete(s string) (V, bool) {
+	root, leaf := txn.delete(txn.root, s)
+	if root == nil {
+		var zero V
+		return zero, false
+	}
+	txn.root = root
+	txn.size--
+	return leaf.val, true
+}
+
+// 返回删除后复制的节点；键不存在时返回 nil
+func (txn *Txn[V]) delete(n *node[V], search string) (*node[V], *leafNode[V]) {
+	if len(search) == 0 {
+		if !n.isLeaf() {
+			return nil, nil
+		}
+		leaf := n.leaf
+		nc := txn.writeNode(n)
+		nc.leaf = nil
+		if n != txn.root && len(nc.edges) == 1 {
+			txn.mergeChild(nc)
+		}
+		txn.topDelete(nc, leaf.key)
+		return nc, leaf
+	}
+
+	label := labelOf(search)
+	child := n.getEdge(label)
+	if child == nil || !strings.HasPrefix(search, child.prefix) {
+		return nil, nil
+	}
+	newChild, leaf := txn.delete(child, search[len(child.prefix):])
+	if newChild == nil {
+		return nil, nil
+	}
+
+	nc := txn.writeNode(n)
+	if !newChild.isLeaf() && len(newChild.edges) == 0 {
+		nc.delEdge(label)
+		if n != txn.root && len(nc.edges) == 1 && !nc.isLeaf() {
+			txn.mergeChild(nc)
+		}
+	} else {
+		nc.updateEdge(label, newChild)
+	}
+	txn.topDelete(nc, leaf.key)
+	return nc, leaf
+}
+
+// 删除键后，缓存中包含该键的节点用其叶子及子节点的缓存重新计算
+func (txn *Txn[V]) topDelete(nc *node[V], key string) {
+	if txn.topK > 0 && topIndex(nc.top, key) >= 0 {
+		nc.top = computeTop(nc, txn.topK, txn.weigh)
+	}
+}
+
+// 将唯一的子节点合并到 n，n 须是本事务中可修改的节点；子节点可能被共享，复制其边而不是直接引用
+func (txn *Txn[V]) mergeChild(n *node[V]) {
+	child := n.edges[0].node
+	n.prefix = n.prefix + child.prefix
+	n.leaf = child.leaf
+	n.edges = make(edges[V], len(child.edges))
+	copy(n.edges, child.edges)
+	n.top = child.top
+}
+
+// Commit 提交事务，返回修改后的索引树；之后事务仍可继续使用，继续修改不影响已提交的索引树
+func (txn *Txn[V]) Commit() *Tree[V] {
+	txn.writable = make(map[*node[V]]struct{})
+	return &Tree[V]{root: txn.root, size: txn.size, merge: txn.merge, topK: txn.topK, weigh: txn.weigh}
+}
+
+// AtomicTree 持有当前发布的索引树：读者通过 Load 取得快照后无锁查询，写者通过 Update 在事务中修改后原子发布
+type AtomicTree[V any] struct {
+	tree atomic.Pointer[Tree[V]]
+	mu   sync.Mutex // 串行化写者
+}
+
+func NewAtomicTree[V any](t *Tree[V]) *AtomicTree[V] {
+	at := &AtomicTree[V]{}
+	at.tree.Store(t)
+	return at
+}
+
+// Load 当前发布的索引树快照，不能通过 Tree.Insert、Tree.Delete 修改
+func (at *AtomicTree[V]) Load() *Tree[V] {
+	return at.tree.Load()
+}
+
+/**
+ * 在事务中修改索引树，fn 返回 nil 时提交并发布新的索引树，返回错误时放弃修改
+ * 多个写者之间串行执行，不阻塞读者
+ */
+func (at *AtomicTree[V]) Update(fn func(txn *Txn[V]) error) error {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+
+	txn := at.tree.Load().Txn()
+	if err := fn(txn); err != nil {
+		return err
+	}
+	at.tree.Store(txn.Commit())
+	return nil
+}
@@ -0,0 +1,175 @@
+package radix
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// 事务中随机插入、删除，提交前后原树都不变，提交得到的树与 map 一致
+func TestTxnSnapshotIsolation(t *testing.T) {
+	r := rand.New(rand.NewSource(7))
+	tree := New[int](nil)
+	m := make(map[string]int)
+	for i := 1; i <= 2000; i++ {
+		k := randomTreeKey(r)
+		tree.Insert(k, i)
+		m[k] = i
+	}
+	snapshot := tree.ToMap()
+
+	for round := 0; round < 5; round++ {
+		txn := tree.Txn()
+		for i := 1; i <= 500; i++ {
+			k := randomTreeKey(r)
+			if r.Intn(3) == 0 {
+				_, ok := txn.Delete(k)
+				if _, exists := m[k]; ok != exists {
+					t.Fatalf("Txn.Delete(%q) = %v, want %v", k, ok, exists)
+				}
+				delete(m, k)
+			} else {
+				if _, err := txn.Insert(k, -i); err != nil {
+					t.Fatal(err)
+				}
+				m[k] = -i
+			}
+			if v, ok := txn.Get(k); ok != (m[k] != 0) || v != m[k] {
+				t.Fatalf("Txn.Get(%q) = %d, %v, want %d", k, v, ok, m[k])
+			}
+		}
+		if txn.Len() != len(m) {
+			t.Fatalf("Txn.Len() = %d, want %d", txn.Len(), len(m))
+		}
+		if !reflect.DeepEqual(tree.ToMap(), snapshot) {
+			t.Fatalf("round %d: uncommitted txn changed the original tree", round)
+		}
+
+		committed := txn.Commit()
+		if !reflect.DeepEqual(tree.ToMap(), snapshot) {
+			t.Fatalf("round %d: Commit changed the original tree", round)
+		}
+		checkTreeAgainstMap(t, r, committed, m)
+		if err := committed.Validate(); err != nil {
+			t.Fatal(err)
+		}
+
+		// 提交后继续使用事务，不影响已提交的树
+		committed_map := committed.ToMap()
+		for i := 0; i < 100; i++ {
+			k := randomTreeKey(r)
+			if _, err := txn.Insert(k, 0); err != nil {
+				t.Fatal(err)
+			}
+			txn.Delete(randomTreeKey(r))
+		}
+		if !reflect.DeepEqual(committed.ToMap(), committed_map) {
+			t.Fatalf("round %d: txn used after Commit changed the committed tree", round)
+		}
+
+		tree, snapshot = committed, committed_map
+	}
+}
+
+// 每次 Update 删除上一轮的全部键并插入新一轮的键，并发读者只能看到完整的某一轮
+func TestAtomicTreeUpdateUnderReaders(t *testing.T) {
+	const keys = 50
+	const rounds = 200
+	round_key := func(i int) string {
+		return fmt.Sprintf("中国银行%d", i)
+	}
+
+	tree := New[int](nil)
+	for i := 0; i < keys; i++ {
+		tree.Insert(round_key(i), 0)
+	}
+	at := NewAtomicTree(tree)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			last := 0
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				snap := at.Load()
+				if snap.Len() != keys {
+					t.Errorf("snapshot has %d keys, want %d", snap.Len(), keys)
+					return
+				}
+				round, count := -1, 0
+				snap.WalkPrefix("中国银行", func(k string, v int) bool {
+					if round == -1 {
+						round = v
+					}
+					if v != round {
+						t.Errorf("snapshot mixes rounds %d and %d", round, v)
+						return true
+					}
+					count++
+					return false
+				})
+				if count != keys {
+					t.Errorf("WalkPrefix saw %d keys, want %d", count, keys)
+					return
+				}
+				if round < last {
+					t.Errorf("round went back from %d to %d", last, round)
+					return
+				}
+				last = round
+			}
+		}()
+	}
+
+	for round := 1; round <= rounds; round++ {
+		err := at.Update(func(txn *Txn[int]) error {
+			for i := 0; i < keys; i++ {
+				if _, ok := txn.Delete(round_key(i)); !ok {
+					return fmt.Errorf("key %d missing", i)
+				}
+			}
+			for i := 0; i < keys; i++ {
+				if _, err := txn.Insert(round_key(i), round); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	// fn 返回错误时放弃修改
+	errAbort := errors.New("abort")
+	before := at.Load()
+	err := at.Update(func(txn *Txn[int]) error {
+		txn.Delete(round_key(0))
+		txn.Insert("哆啦A梦", 1)
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("Update returned %v, want %v", err, errAbort)
+	}
+	if at.Load() != before {
+		t.Fatal("failed Update published a new tree")
+	}
+	if v, ok := before.Get(round_key(0)); !ok || v != rounds {
+		t.Fatalf("Get(%q) = %d, %v after failed Update", round_key(0), v, ok)
+	}
+	if _, ok := before.Get("哆啦A梦"); ok {
+		t.Fatal("failed Update changed the published tree")
+	}
+}
@@ -0,0 +1,79 @@
+package radix
+
+// 索引树的结构校验，用于测试及排查问题
+
+import (
+	"fmt"
+)
+
+/**
+ * Validate 校验索引树的结构，返回发现的第一个问题
+ * 1. 边按 label 严格递增，label 为子节点 prefix 的首个 rune，非根节点的 prefix 不为空
+ * 2. 叶子的 key 等于从根节点到该节点的 prefix 拼接
+ * 3. 非根节点要么有叶子，要么至少有两个子节点（没有空节点及可合并的单链）
+ * 4. size 等于叶子数量；开启 top-k 缓存时，各节点的缓存与子树一致
+ */
+func (t *Tree[V]) Validate() error {
+	if t.root == nil {
+		return fmt.Errorf("root is nil")
+	}
+	if t.root.prefix != "" {
+		return fmt.Errorf("root prefix %q is not empty", t.root.prefix)
+	}
+	leaves, err := t.validateNode(t.root, "", true)
+	if err != nil {
+		return err
+	}
+	if leaves != t.size {
+		return fmt.Errorf("size %d does not match %d leaves", t.size, leaves)
+	}
+	return nil
+}
+
+func (t *Tree[V]) validateNode(n *node[V], path string, isRoot bool) (int, error) {
+	if !isRoot {
+		if n.prefix == "" {
+			return 0, fmt.Errorf("node under %q has empty prefix", path)
+		}
+		if !n.isLeaf() && len(n.edges) < 2 {
+			return 0, fmt.Errorf("node %q without leaf has %d edges", path, len(n.edges))
+		}
+	}
+	if n.isLeaf() && n.leaf.key != path {
+		return 0, fmt.Errorf("leaf key %q does not match path %q", n.leaf.key, path)
+	}
+
+	leaves := 0
+	if n.isLeaf() {
+		leaves++
+	}
+	for i, e := range n.edges {
+		if e.node == nil {
+			return 0, fmt.Errorf("node %q has nil edge %d", path, i)
+		}
+		if i > 0 && n.edges[i-1].label >= e.label {
+			return 0, fmt.Errorf("edges of node %q are not sorted at %d", path, i)
+		}
+		if e.label != labelOf(e.node.prefix) {
+			return 0, fmt.Errorf("edge label %q does not match prefix %q under %q", e.label, e.node.prefix, path)
+		}
+		count, err := t.validateNode(e.node, path+e.node.prefix, false)
+		if err != nil {
+			return 0, err
+		}
+		leaves += count
+	}
+
+	if t.topK > 0 {
+		expected := computeTop(n, t.topK, t.weigh)
+		if len(expected) != len(n.top) {
+			return 0, fmt.Errorf("top-k cache of node %q has %d entries, expected %d", path, len(n.top), len(expected))
+		}
+		for i := range expected {
+			if expected[i].leaf.key != n.top[i].leaf.key || expected[i].weight != n.top[i].weight {
+				return 0, fmt.Errorf("top-k cache of node %q differs at %d: %q != %q", path, i, n.top[i].leaf.key, expected[i].leaf.key)
+			}
+		}
+	}
+	return leaves, nil
+}
@@ -0,0 +1,90 @@
+package radix
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// 随机插入、删除及按前缀删除，每次操作后结构校验通过；Optimize 后键不变且仍校验通过
+func TestValidateAfterRandomOps(t *testing.T) {
+	r := rand.New(rand.NewSource(23))
+	for round := 0; round < 10; round++ {
+		tree := New[int](nil)
+		if round%2 == 0 {
+			tree.EnableTopK(3, topKTestWeight)
+		}
+		m := make(map[string]int)
+		for i := 1; i <= 1000; i++ {
+			k := randomTreeKey(r)
+			switch op := r.Intn(10); {
+			case op < 3:
+				tree.Delete(k)
+				delete(m, k)
+			case op == 3:
+				prefix := string([]rune(k)[:1])
+				deleted := tree.DeletePrefix(prefix)
+				count := 0
+				for key := range m {
+					if strings.HasPrefix(key, prefix) {
+						delete(m, key)
+						count++
+					}
+				}
+				if deleted != count {
+					t.Fatalf("DeletePrefix(%q) = %d, want %d", prefix, deleted, count)
+				}
+			default:
+				tree.Insert(k, r.Intn(1000))
+				m[k], _ = tree.Get(k)
+			}
+			if err := tree.Validate(); err != nil {
+				t.Fatalf("round %d op %d: %v", round, i, err)
+			}
+		}
+
+		tree.Optimize()
+		if err := tree.Validate(); err != nil {
+			t.Fatalf("round %d after Optimize: %v", round, err)
+		}
+		checkTreeAgainstMap(t, r, tree, m)
+	}
+}
+
+// Optimize 删除空节点并合并没有叶子的单链，修复后 Validate 通过
+func TestOptimizeRepairsChain(t *testing.T) {
+	leaf := func(key string, v int) *leafNode[int] {
+		return &leafNode[int]{key: key, val: v}
+	}
+	tree := New[int](nil)
+	// 中 -> 国 -> {银行(叶子), 人(空节点)}，哆 -> 啦A梦(叶子)
+	tree.root.edges = edges[int]{
+		{label: 'd', node: &node[int]{prefix: "dlam", leaf: leaf("dlam", 3)}},
+		{label: '中', node: &node[int]{prefix: "中", edges: edges[int]{
+			{label: '国', node: &node[int]{prefix: "国", edges: edges[int]{
+				{label: '人', node: &node[int]{prefix: "人"}},
+				{label: '银', node: &node[int]{prefix: "银行", leaf: leaf("中国银行", 1)}},
+			}}},
+		}}},
+		{label: '哆', node: &node[int]{prefix: "哆", edges: edges[int]{
+			{label: '啦', node: &node[int]{prefix: "啦A梦", leaf: leaf("哆啦A梦", 2)}},
+		}}},
+	}
+	tree.size = 3
+	if err := tree.Validate(); err == nil {
+		t.Fatal("Validate accepted a tree with an empty node and single-child chains")
+	}
+
+	tree.Optimize()
+	if err := tree.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{"dlam": 3, "中国银行": 1, "哆啦A梦": 2}
+	if got := tree.ToMap(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("ToMap() = %v, want %v", got, want)
+	}
+	if len(tree.root.edges) != 3 || tree.root.edges[1].node.prefix != "中国银行" || tree.root.edges[2].node.prefix != "哆啦A梦" {
+		t.Errorf("chains were not merged into single nodes")
+	}
+}
@@ -0,0 +1,241 @@
+package radix
+
+// 基于已创建的索引数据库召回字典词：查询语句 -> 索引词 index_words -> dict_index_ids -> 字典词 dict_words
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// sqlite 单条语句最多999个参数，批量查询时每批次的最大参数数量
+const searchBatchSize = 800
+
+type SearchResult struct {
+	DictWord
+	Score  float64    `json:"score"`  // 多路融合后的得分
+	Routes []RouteHit `json:"routes"` // 命中该字典词的各路召回
+}
+
+type Searcher struct {
+	db        *sqlx.DB
+	indexPath string
+	options   *IndexOptions               // 创建索引时的参数，查询时按相同的规则切词
+	memory    atomic.Pointer[MemoryIndex] // 内存索引，加载后索引词召回不再查询数据库；查询过程中可以被替换
+}
+
+// 一次查询使用的查询器：查询开始时读取一次内存索引，各路召回使用同一个内存索引
+type searcher_view struct {
+	*Searcher
+	memory *MemoryIndex // 未加载内存索引时为 nil
+}
+
+func (s *Searcher) view() *searcher_view {
+	return &searcher_view{Searcher: s, memory: s.memory.Load()}
+}
+
+/**
+ * 打开索引数据库，创建查询器
+ * @param index_path 索引数据库路径
+ * @param max_memory_map_size 最大内存映射大小，0 表示不限制
+ */
+func NewSearcher(index_path string, max_memory_map_size uint64) (*Searcher, error) {
+	db, err := ReadIndex(index_path, max_memory_map_size)
+	if err != nil {
+		return nil, err
+	}
+	options, err := load_index_options(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Searcher{
+		db:        db,
+		indexPath: index_path,
+		options:   options,
+	}, nil
+}
+
+func (s *Searcher) Close() error {
+	return s.db.Close()
+}
+
+/**
+ * 查询字典词，使用默认的多路召回配置
+ * @param query 原始查询语句
+ * @param limit 返回的最大条数，<=0 时默认20条
+ * @return []SearchResult 按得分从高到低排序的字典词
+ */
+func (s *Searcher) Search(query string, limit int) ([]SearchResult, error) {
+	opts := DefaultSearchOptions()
+	opts.Limit = limit
+	return s.SearchWithOptions(query, opts)
+}
+
+// 索引词的权重，与创建索引时写入 index_words.word_len 的规则相同
+func index_word_len(word_type int, word string) int {
+	if word_type == 2 {
+		return len([]rune(word))
+	}
+	return _step3_calc_index_word_weight(word)
+}
+
+/**
+ * 查询存在的索引词及其对应的字典词ID，已加载内存索引时直接查询内存
+ * @return []IndexWord 存在的索引词，DictId 为对应的字典词ID
+ */
+func (s *searcher_view) lookup_index_words(words []string, word_type int) ([]IndexWord, error) {
+	if s.memory != nil {
+		results := make([]IndexWord, 0)
+		for _, w := range words {
+			dict_ids, exists := s.memory.Get(word_type, w)
+			if !exists {
+				continue
+			}
+			iw := IndexWord{Type: word_type, Word: w, WordLen: index_word_len(word_type, w), DictId: make(map[int]bool, len(dict_ids))}
+			for _, dict_id := range dict_ids {
+				iw.DictId[int(dict_id)] = true
+			}
+			results = append(results, iw)
+		}
+		return results, nil
+	}
+
+	index_words, err := search_query_index_words(s.db, words, word_type)
+	if err != nil || len(index_words) == 0 {
+		return index_words, err
+	}
+	index_ids := make([]int, 0, len(index_words))
+	for _, iw := range index_words {
+		index_ids = append(index_ids, iw.ID)
+	}
+	index_dict_ids, err := search_query_index_dict_ids(s.db, index_ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range index_words {
+		index_words[i].DictId = make(map[int]bool, len(index_dict_ids[index_words[i].ID]))
+		for _, dict_id := range index_dict_ids[index_words[i].ID] {
+			index_words[i].DictId[dict_id] = true
+		}
+	}
+	return index_words, nil
+}
+
+// 按索引词查询 index_words，返回存在的索引词
+func search_query_index_words(db *sqlx.DB, words []string, word_type int) ([]IndexWord, error) {
+	results := make([]IndexWord, 0)
+	for i := 0; i < len(words); i += searchBatchSize {
+		end := min(i+searchBatchSize, len(words))
+
+		query, args, err := sqlx.In("SELECT id, type, word, word_len FROM index_words WHERE type = ? AND word IN (?)", word_type, words[i:end])
+		if err != nil {
+			return nil, fmt.Errorf("failed to build query: %w", err)
+		}
+
+		var batch []IndexWord
+		if err := db.Select(&batch, db.Rebind(query), args...); err != nil {
+			return nil, fmt.Errorf("failed to query index_words: %w", err)
+		}
+		results = append(results, batch...)
+	}
+	return results, nil
+}
+
+// 按索引词ID查询 index_words
+func search_query_index_words_by_ids(db *sqlx.DB, index_ids []int) ([]IndexWord, error) {
+	results := make([]IndexWord, 0, len(index_ids))
+	for i := 0; i < len(index_ids); i += searchBatchSize {
+		end := min(i+searchBatchSize, len(index_ids))
+
+		query, args, err := sqlx.In("SELECT id, type, word, word_len FROM index_words WHERE id IN (?)", index_ids[i:end])
+		if err != nil {
+			return nil, fmt.Errorf("failed to build query: %w", err)
+		}
+
+		var batch []IndexWord
+		if err := db.Select(&batch, db.Rebind(query), args...); err != nil {
+			return nil, fmt.Errorf("failed to query index_words: %w", err)
+		}
+		results = append(results, batch...)
+	}
+	return results, nil
+}
+
+// 按索引词ID查询 dict_index_ids，返回 index_id -> []dict_id
+func search_query_index_dict_ids(db *sqlx.DB, index_ids []int) (map[int][]int, error) {
+	results := make(map[int][]int)
+	for i := 0; i < len(index_ids); i += searchBatchSize {
+		end := min(i+searchBatchSize, len(index_ids))
+
+		query, args, err := sqlx.In("SELECT index_id, dict_id FROM dict_index_ids WHERE index_id IN (?)", index_ids[i:end])
+		if err != nil {
+			return nil, fmt.Errorf("failed to build query: %w", err)
+		}
+
+		rows, err := db.Queryx(db.Rebind(query), args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query dict_index_ids: %w", err)
+		}
+		for rows.Next() {
+			var index_id, dict_id int
+			if err := rows.Scan(&index_id, &dict_id); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan dict_index_ids: %w", err)
+			}
+			results[index_id] = append(results[index_id], dict_id)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read dict_index_ids: %w", err)
+		}
+	}
+	return results, nil
+}
+
+// 按字典词ID查询 dict_words
+func search_query_dict_words(db *sqlx.DB, dict_ids []int) (map[int]DictWord, error) {
+	results := make(map[int]DictWord, len(dict_ids))
+	for i := 0; i < len(dict_ids); i += searchBatchSize {
+		end := min(i+searchBatchSize, len(dict_ids))
+
+		query, args, err := sqlx.In("SELECT id, dict, name, data, word_chars, word_pinyin FROM dict_words WHERE id IN (?)", dict_ids[i:end])
+		if err != nil {
+			return nil, fmt.Errorf("failed to build query: %w", err)
+		}
+
+		var batch []DictWord
+		if err := db.Select(&batch, db.Rebind(query), args...); err != nil {
+			return nil, fmt.Errorf("failed to query dict_words: %w", err)
+		}
+		for _, dw := range batch {
+			results[dw.ID] = dw
+		}
+	}
+	return results, nil
+}
+
+// 为已排序的结果填充字典词内容，保持原有顺序
+func search_fill_dict_words(db *sqlx.DB, ranked []*SearchResult) ([]SearchResult, error) {
+	dict_ids := make([]int, 0, len(ranked))
+	for _, sr := range ranked {
+		dict_ids = append(dict_ids, sr.ID)
+	}
+	dict_words, err := search_query_dict_words(db, dict_ids)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, 0, len(ranked))
+	for _, sr := range ranked {
+		dw, exists := dict_words[sr.ID]
+		if !exists {
+			continue
+		}
+		sr.DictWord = dw
+		results = append(results, *sr)
+	}
+	return results, nil
+}
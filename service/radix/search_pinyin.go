@@ -0,0 +1,165 @@
+package radix
+
+// 拼音召回：将查询中的汉字按创建索引时相同的规则转换为拼音，拼音输入切分音节后，召回 Type 1 拼音索引词
+// 拼音首字母召回：无法切分为音节的纯字母输入视为拼音首字母，召回 Type 2 拼音首字母索引词
+
+import (
+	"strings"
+)
+
+// 拼音索引词至少包含的音节数量
+const pinyinMinSyllables = 2
+
+// 拼音首字母索引词至少包含的字母数量
+const pinyinMinInitials = 2
+
+// 统一拼音的空格：小写，音节之间只保留一个空格
+func normalize_pinyin(py string) string {
+	return strings.Join(strings.Fields(strings.ToLower(py)), " ")
+}
+
+// 短语转换为拼音：含汉字的短语逐字转换为拼音，多音字有多个读音；非汉字短语（拼音输入）切分音节，可能有多种切分
+func phrase_to_pinyins(s *searcher_view, p *index_phrase) ([]string, error) {
+	word := p.ToString()
+	if !HasHanChar(word) {
+		return s.segment_pinyin(word, pinyinSegmentLimit)
+	}
+	pys, ok := AllPinyinOfWord(word, s.options.PinyinMaxReadings)
+	if !ok {
+		return nil, nil
+	}
+	results := make([]string, 0, len(pys))
+	for _, py := range pys {
+		if py = normalize_pinyin(py); len(py) > 0 {
+			results = append(results, py)
+		}
+	}
+	return results, nil
+}
+
+/**
+ * 分析查询语句，生成用于召回的拼音索引词，支持汉字、拼音以及汉字拼音混合输入，如 "哆la a梦"、"duolaameng"
+ * 1. 每个短语转换为拼音，不带空格的拼音输入切分为音节
+ * 2. 相邻的多个短语拼接为一个拼音，汉字拼音混合输入会被切分为多个短语
+ * 3. 与创建索引时的前缀切词对应，每个拼音去掉开头的音节，直至少于2个音节
+ * @return []string 拼音索引词
+ * @return error 切分拼音输入时查询索引失败
+ */
+func analyze_pinyin_query(s *searcher_view, sentence *IndexSentence) ([]string, error) {
+	phrase_pinyins := make([][]string, 0, len(sentence.index_phrases))
+	for _, p := range sentence.index_phrases {
+		pys, err := phrase_to_pinyins(s, p)
+		if err != nil {
+			return nil, err
+		}
+		phrase_pinyins = append(phrase_pinyins, pys)
+	}
+
+	word_set := make(map[string]bool)
+	for i := 0; i < len(phrase_pinyins); i++ {
+		joined := []string{""}
+		for j := i; j < len(phrase_pinyins) && len(phrase_pinyins[j]) > 0; j++ {
+			// 多种切分的组合数量有上限
+			next := make([]string, 0, len(joined)*len(phrase_pinyins[j]))
+			for _, prefix := range joined {
+				for _, py := range phrase_pinyins[j] {
+					if len(next) >= pinyinSegmentMaxCandidates {
+						break
+					}
+					next = append(next, strings.TrimSpace(prefix+" "+py))
+				}
+			}
+			joined = next
+			for _, py := range joined {
+				syllables := strings.Fields(py)
+				for k := 0; len(syllables)-k >= pinyinMinSyllables; k++ {
+					word_set[strings.Join(syllables[k:], " ")] = true
+				}
+			}
+		}
+	}
+
+	words := make([]string, 0, len(word_set))
+	for w := range word_set {
+		words = append(words, w)
+	}
+	return words, nil
+}
+
+func recall_route_pinyin(s *searcher_view, sentence *IndexSentence) (map[int]*RouteHit, error) {
+	pys, err := analyze_pinyin_query(s, sentence)
+	if err != nil {
+		return nil, err
+	}
+	words := make(map[string]float64)
+	for _, w := range pys {
+		words[w] = 1
+	}
+	hits, err := search_recall_index_words(s, words, 1)
+	if err != nil || len(hits) == 0 {
+		return hits, err
+	}
+	if err := boost_full_pinyin_hits(s, hits); err != nil {
+		return nil, err
+	}
+	return hits, nil
+}
+
+/**
+ * 命中的拼音索引词覆盖字典词的整个短语时得分加倍，排在只命中后缀的字典词之前
+ * 如 "shu dian" 同时是 书店 的全部拼音和 先锋书店 的后缀拼音，书店 排在前面
+ * 多音字的读音可能与 word_pinyin 不同，所以按音节数量而不是拼音本身判断
+ */
+func boost_full_pinyin_hits(s *searcher_view, hits map[int]*RouteHit) error {
+	dict_ids := make([]int, 0, len(hits))
+	for dict_id := range hits {
+		dict_ids = append(dict_ids, dict_id)
+	}
+	dict_words, err := search_query_dict_words(s.db, dict_ids)
+	if err != nil {
+		return err
+	}
+	for dict_id, hit := range hits {
+		phrase_lens := make(map[int]bool)
+		for _, py := range strings.Split(dict_words[dict_id].WordPinyin, "|") {
+			phrase_lens[len(strings.Fields(py))] = true
+		}
+		for _, w := range hit.Matches {
+			if phrase_lens[len(strings.Fields(w))] {
+				hit.Score *= 2
+				break
+			}
+		}
+	}
+	return nil
+}
+
+/**
+ * 分析查询语句，判断是否为拼音首字母输入，如 "dlam"
+ * 只包含字母、去除空格后至少2个字母、且无法完整切分为拼音音节时，视为拼音首字母输入
+ * 与创建索引时的前缀切词对应，去掉开头的字母，直至少于2个字母
+ * @return []string 拼音首字母索引词，非首字母输入时为空
+ */
+func analyze_initials_query(query string) []string {
+	letters := strings.Join(strings.Fields(strings.ToLower(query)), "")
+	if !is_lower_letters(letters) || len(letters) < pinyinMinInitials {
+		return nil
+	}
+	if len(segment_pinyin_syllables(letters)) > 0 {
+		return nil
+	}
+
+	words := make([]string, 0, len(letters))
+	for k := 0; len(letters)-k >= pinyinMinInitials; k++ {
+		words = append(words, letters[k:])
+	}
+	return words
+}
+
+func recall_route_initials(s *searcher_view, sentence *IndexSentence) (map[int]*RouteHit, error) {
+	words := make(map[string]float64)
+	for _, w := range analyze_initials_query(strings.Join(sentence.ToWords(), " ")) {
+		words[w] = 1
+	}
+	return search_recall_index_words(s, words, 2)
+}
@@ -0,0 +1,53 @@
+package radix
+
+import (
+	"testing"
+)
+
+// 拼音、汉字及汉字拼音混合的查询都能通过拼音召回
+func TestSearchPinyinMixedQuery(t *testing.T) {
+	s := open_test_searcher(t, build_test_index(t, map[string][]string{
+		"toys": {"哆啦A梦", "哆啦美", "洗发水", "沐浴露"},
+	}, nil))
+	cases := []struct {
+		query string
+		want  string
+	}{
+		{"duo la a meng", "哆啦A梦"},
+		{"哆la a梦", "哆啦A梦"},
+		{"duo啦 a meng", "哆啦A梦"},
+		{"xi fa shui", "洗发水"},
+		{"mu yu 露", "沐浴露"},
+	}
+	for _, c := range cases {
+		results, err := s.Search(c.query, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) == 0 || results[0].Name != c.want {
+			t.Errorf("Search(%s) = %v, want %s first", c.query, search_result_names(results), c.want)
+			continue
+		}
+		if search_route_hit(results[0], RoutePinyin) == nil {
+			t.Errorf("Search(%s): %s was not recalled by the pinyin route", c.query, c.want)
+		}
+	}
+}
+
+// 拼音覆盖整个字典词的排在只命中后缀的字典词之前
+func TestSearchPinyinPrefersFullMatch(t *testing.T) {
+	s := open_test_searcher(t, build_test_index(t, map[string][]string{
+		"shops": {"先锋书店", "书店"},
+	}, nil))
+	results, err := s.Search("shudian", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := search_result_names(results); len(names) != 2 || names[0] != "书店" {
+		t.Fatalf("Search(shudian) = %v, want 书店 first", names)
+	}
+	full, suffix := search_route_hit(results[0], RoutePinyin), search_route_hit(results[1], RoutePinyin)
+	if full == nil || suffix == nil || full.Rank != 1 || full.Score <= suffix.Score {
+		t.Errorf("pinyin route hits: 书店 %+v, 先锋书店 %+v", full, suffix)
+	}
+}
@@ -0,0 +1,487 @@
+package radix
+
+// 多路召回：字符、拼音、掩码、乱序等各路召回并行执行，按倒数排名融合（RRF）得到最终排序
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type RecallRoute int
+
+const (
+	RouteChar        RecallRoute = iota // 字符召回，Type 0 原始索引词
+	RoutePinyin                         // 拼音召回，Type 1 拼音索引词
+	RouteMask                           // 掩码召回，Type 0 中带 * 的掩码索引词
+	RouteChaos                          // 乱序召回，Type 0 中按 rune 排序的乱序索引词
+	RouteInitials                       // 拼音首字母召回，Type 2 拼音首字母索引词
+	RouteFuzzyPinyin                    // 模糊拼音召回，Type 1 拼音索引词及 Type 3 模糊拼音索引词
+)
+
+// AllRecallRoutes 全部召回路由，按融合时的先后顺序排列
+var AllRecallRoutes = []RecallRoute{RouteChar, RoutePinyin, RouteMask, RouteChaos, RouteInitials, RouteFuzzyPinyin}
+
+func (r RecallRoute) String() string {
+	switch r {
+	case RouteChar:
+		return "char"
+	case RoutePinyin:
+		return "pinyin"
+	case RouteMask:
+		return "mask"
+	case RouteChaos:
+		return "chaos"
+	case RouteInitials:
+		return "initials"
+	case RouteFuzzyPinyin:
+		return "fuzzy_pinyin"
+	default:
+		return fmt.Sprintf("route(%d)", int(r))
+	}
+}
+
+type RouteHit struct {
+	Route    RecallRoute `json:"route"`
+	Rank     int         `json:"rank"`    // 在该路召回中的排名，从1开始
+	Score    float64     `json:"score"`   // 在该路召回中的得分
+	Matches  []string    `json:"matches"` // 命中的索引词
+	Masks    []MaskMatch `json:"masks,omitempty"`
+	Distance int         `json:"distance,omitempty"` // 命中片段与查询的差异，乱序召回为逆序对数量
+}
+
+// 掩码召回命中的掩码索引词，及查询中被掩码的位置
+type MaskMatch struct {
+	Word      string   `json:"word"`      // 命中的掩码索引词
+	Phrase    int      `json:"phrase"`    // 被掩码的查询短语序号，从0开始
+	Positions []int    `json:"positions"` // 被掩码的 char 在查询短语中的位置，从0开始
+	Chars     []string `json:"chars"`     // 查询中被掩码的 char
+}
+
+type SearchOptions struct {
+	Limit      int                     `json:"limit"`       // 返回的最大条数，<=0 时默认20条
+	RouteLimit int                     `json:"route_limit"` // 每路召回参与融合的最大条数，<=0 时为 Limit 的10倍
+	Routes     map[RecallRoute]bool    `json:"routes"`      // 各路召回的开关，未设置的路由默认开启
+	Weights    map[RecallRoute]float64 `json:"weights"`     // 各路召回的融合权重，未设置的路由使用默认权重
+	RRFK       float64                 `json:"rrf_k"`       // 倒数排名融合的平滑常数，<=0 时默认60
+}
+
+// 各路召回的默认融合权重，精确的字符召回最高
+var defaultRouteWeights = map[RecallRoute]float64{
+	RouteChar:        1.0,
+	RoutePinyin:      0.8,
+	RouteMask:        0.6,
+	RouteChaos:       0.6,
+	RouteInitials:    0.5,
+	RouteFuzzyPinyin: 0.7, // 略低于精确的拼音召回
+}
+
+func DefaultSearchOptions() *SearchOptions {
+	return &SearchOptions{
+		Limit:   20,
+		Routes:  map[RecallRoute]bool{},
+		Weights: map[RecallRoute]float64{},
+		RRFK:    60,
+	}
+}
+
+func (o *SearchOptions) IsRouteEnabled(route RecallRoute) bool {
+	if o.Routes == nil {
+		return true
+	}
+	enabled, exists := o.Routes[route]
+	return !exists || enabled
+}
+
+func (o *SearchOptions) RouteWeight(route RecallRoute) float64 {
+	if w, exists := o.Weights[route]; exists {
+		return w
+	}
+	return defaultRouteWeights[route]
+}
+
+// 每路召回根据查询语句生成索引词并召回字典词，返回 dict_id -> 命中信息，由调用方负责排名
+type route_recall_fn func(s *searcher_view, sentence *IndexSentence) (map[int]*RouteHit, error)
+
+var recallRouteFns = map[RecallRoute]route_recall_fn{
+	RouteChar:        recall_route_char,
+	RoutePinyin:      recall_route_pinyin,
+	RouteMask:        recall_route_mask,
+	RouteChaos:       recall_route_chaos,
+	RouteInitials:    recall_route_initials,
+	RouteFuzzyPinyin: recall_route_fuzzy_pinyin,
+}
+
+/**
+ * 多路召回查询字典词
+ * @param query 原始查询语句
+ * @param opts 召回配置，为 nil 时使用默认配置
+ * @return []SearchResult 按融合得分从高到低排序的字典词
+ */
+func (s *Searcher) SearchWithOptions(query string, opts *SearchOptions) ([]SearchResult, error) {
+	if opts == nil {
+		opts = DefaultSearchOptions()
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	route_limit := opts.RouteLimit
+	if route_limit <= 0 {
+		route_limit = limit * 10
+	}
+	rrf_k := opts.RRFK
+	if rrf_k <= 0 {
+		rrf_k = 60
+	}
+
+	sentence := NewIndexSentence(query)
+	q := s.view()
+
+	// 各路召回并行执行
+	routes := make([]RecallRoute, 0, len(AllRecallRoutes))
+	for _, route := range AllRecallRoutes {
+		if opts.IsRouteEnabled(route) {
+			routes = append(routes, route)
+		}
+	}
+	route_hits := make([][]*RouteHit, len(routes))
+	route_dict_ids := make([][]int, len(routes))
+	route_errs := make([]error, len(routes))
+	var wg sync.WaitGroup
+	for i, route := range routes {
+		wg.Add(1)
+		go func(i int, route RecallRoute) {
+			defer wg.Done()
+			hits, err := recallRouteFns[route](q, sentence)
+			if err != nil {
+				route_errs[i] = fmt.Errorf("recall route %s failed: %w", route, err)
+				return
+			}
+			route_dict_ids[i], route_hits[i] = rank_route_hits(route, hits, route_limit)
+		}(i, route)
+	}
+	wg.Wait()
+	for _, err := range route_errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 倒数排名融合：score = Σ weight / (k + rank)
+	fused := make(map[int]*SearchResult)
+	for i, route := range routes {
+		weight := opts.RouteWeight(route)
+		for j, hit := range route_hits[i] {
+			dict_id := route_dict_ids[i][j]
+			sr, exists := fused[dict_id]
+			if !exists {
+				sr = &SearchResult{DictWord: DictWord{ID: dict_id}}
+				fused[dict_id] = sr
+			}
+			sr.Score += weight / (rrf_k + float64(hit.Rank))
+			sr.Routes = append(sr.Routes, *hit)
+		}
+	}
+
+	ranked := make([]*SearchResult, 0, len(fused))
+	for _, sr := range fused {
+		ranked = append(ranked, sr)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].ID < ranked[j].ID
+	})
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+
+	return search_fill_dict_words(s.db, ranked)
+}
+
+// 对单路召回的结果排名，返回排名后的 dict_id 及对应的命中信息
+func rank_route_hits(route RecallRoute, hits map[int]*RouteHit, route_limit int) ([]int, []*RouteHit) {
+	dict_ids := make([]int, 0, len(hits))
+	for dict_id := range hits {
+		dict_ids = append(dict_ids, dict_id)
+	}
+	sort.Slice(dict_ids, func(i, j int) bool {
+		si, sj := hits[dict_ids[i]].Score, hits[dict_ids[j]].Score
+		if si != sj {
+			return si > sj
+		}
+		return dict_ids[i] < dict_ids[j]
+	})
+	if len(dict_ids) > route_limit {
+		dict_ids = dict_ids[:route_limit]
+	}
+
+	ranked := make([]*RouteHit, 0, len(dict_ids))
+	for i, dict_id := range dict_ids {
+		hit := hits[dict_id]
+		hit.Route = route
+		hit.Rank = i + 1
+		ranked = append(ranked, hit)
+	}
+	return dict_ids, ranked
+}
+
+/**
+ * 按索引词召回字典词，字典词在该路召回中的得分为命中索引词的权重之和
+ * @param words 待查询的索引词 -> 权重系数
+ * @param word_type 索引词类型
+ */
+func search_recall_index_words(s *searcher_view, words map[string]float64, word_type int) (map[int]*RouteHit, error) {
+	hits := make(map[int]*RouteHit)
+	if len(words) == 0 {
+		return hits, nil
+	}
+
+	query_words := make([]string, 0, len(words))
+	for w := range words {
+		query_words = append(query_words, w)
+	}
+	index_words, err := s.lookup_index_words(query_words, word_type)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, iw := range index_words {
+		for dict_id := range iw.DictId {
+			hit, exists := hits[dict_id]
+			if !exists {
+				hit = &RouteHit{}
+				hits[dict_id] = hit
+			}
+			hit.Score += float64(iw.WordLen) * words[iw.Word]
+			hit.Matches = append(hit.Matches, iw.Word)
+		}
+	}
+	return hits, nil
+}
+
+// 与创建索引时相同的方式切分查询语句，得到不带掩码、不乱序的字符索引词
+func search_char_words(s *searcher_view, sentence *IndexSentence) []string {
+	opts := *s.options
+	opts.MaskCount = 0
+	words := make([]string, 0)
+	for _, w := range sentence.SplitToIndexWordsWithOptions(&opts, false) {
+		w = strings.TrimSpace(w)
+		if len(w) > 0 {
+			words = append(words, w)
+		}
+	}
+	return words
+}
+
+func recall_route_char(s *searcher_view, sentence *IndexSentence) (map[int]*RouteHit, error) {
+	words := make(map[string]float64)
+	for _, w := range search_char_words(s, sentence) {
+		words[w] = 1
+	}
+	hits, err := search_recall_index_words(s, words, 0)
+	if err != nil || len(hits) == 0 {
+		return hits, err
+	}
+
+	// 本身已按 rune 有序的查询切词，可能命中的是其他字典词的乱序索引词，须校验字典词中确实包含该切词
+	verify_ids := make([]int, 0)
+	for dict_id, hit := range hits {
+		for _, w := range hit.Matches {
+			if w == sort_word_runes(w) {
+				verify_ids = append(verify_ids, dict_id)
+				break
+			}
+		}
+	}
+	if len(verify_ids) == 0 {
+		return hits, nil
+	}
+	dict_words, err := search_query_dict_words(s.db, verify_ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, dict_id := range verify_ids {
+		hit := hits[dict_id]
+		word_chars := dict_words[dict_id].WordChars
+		matches := make([]string, 0, len(hit.Matches))
+		score := 0.0
+		for _, w := range hit.Matches {
+			if w != sort_word_runes(w) || strings.Contains(word_chars, w) {
+				matches = append(matches, w)
+				score += float64(_step3_calc_index_word_weight(w))
+			}
+		}
+		if len(matches) == 0 {
+			delete(hits, dict_id)
+			continue
+		}
+		hit.Matches = matches
+		hit.Score = score
+	}
+	return hits, nil
+}
+
+/**
+ * 掩码召回：按创建索引时的掩码数量，对查询短语的每个切词生成掩码变体，召回掩码索引词；
+ * 被掩码位置上的错字不影响命中，用于召回单字错误的查询
+ */
+func recall_route_mask(s *searcher_view, sentence *IndexSentence) (map[int]*RouteHit, error) {
+	if s.options.MaskCount <= 0 {
+		return map[int]*RouteHit{}, nil
+	}
+
+	mask_matches := make(map[string]MaskMatch)
+	words := make(map[string]float64)
+	for pi, p := range sentence.index_phrases {
+		if p.Length() < s.options.MinSplitLen {
+			continue
+		}
+		for _, offset := range p.split_offsets(s.options.MinSplitLen) {
+			split_word := _index_chars_to_str(p.index_chars[offset:])
+			for _, mw := range mask_split_word(split_word, s.options) {
+				if _, exists := mask_matches[mw.word]; exists {
+					continue
+				}
+				mm := MaskMatch{Word: mw.word, Phrase: pi}
+				for _, pos := range mw.positions {
+					mm.Positions = append(mm.Positions, offset+pos)
+					mm.Chars = append(mm.Chars, p.index_chars[offset+pos].CharStr)
+				}
+				mask_matches[mw.word] = mm
+				words[mw.word] = 1
+			}
+		}
+	}
+
+	hits, err := search_recall_index_words(s, words, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, hit := range hits {
+		for _, w := range hit.Matches {
+			hit.Masks = append(hit.Masks, mask_matches[w])
+		}
+	}
+	return hits, nil
+}
+
+/**
+ * 乱序召回：将查询的每个切词按 rune 排序后召回乱序索引词，再用字典词的 word_chars 校验；
+ * 字典词中须存在与查询切词字符相同的连续片段，按片段与查询之间的逆序对数量降权，逆序过多的纯异序词丢弃
+ */
+func recall_route_chaos(s *searcher_view, sentence *IndexSentence) (map[int]*RouteHit, error) {
+	words := make(map[string]float64)
+	chaos_sources := make(map[string][]string) // 乱序索引词 -> 查询切词
+	for _, w := range search_char_words(s, sentence) {
+		chaos := sort_word_runes(w)
+		if len(chaos) > 0 {
+			words[chaos] = 1
+			chaos_sources[chaos] = append(chaos_sources[chaos], w)
+		}
+	}
+	hits, err := search_recall_index_words(s, words, 0)
+	if err != nil || len(hits) == 0 {
+		return hits, err
+	}
+
+	dict_ids := make([]int, 0, len(hits))
+	for dict_id := range hits {
+		dict_ids = append(dict_ids, dict_id)
+	}
+	dict_words, err := search_query_dict_words(s.db, dict_ids)
+	if err != nil {
+		return nil, err
+	}
+	for dict_id, hit := range hits {
+		best := -1
+		for _, chaos := range hit.Matches {
+			for _, src := range chaos_sources[chaos] {
+				inversions, ok := chaos_min_inversions(src, dict_words[dict_id].WordChars)
+				if ok && (best < 0 || inversions < best) {
+					best = inversions
+				}
+			}
+		}
+		if best < 0 || best > chaosMaxInversions {
+			delete(hits, dict_id)
+			continue
+		}
+		hit.Distance = best
+		hit.Score = hit.Score / float64(1+best)
+	}
+	return hits, nil
+}
+
+// 乱序召回允许的最大逆序对数量，相邻两字互换为1，超过则视为纯异序词丢弃
+const chaosMaxInversions = 2
+
+/**
+ * 在字典词的 word_chars 中查找与查询切词字符相同的连续片段，返回片段相对查询的最少逆序对数量
+ * @param query_word 查询切词
+ * @param word_chars 字典词的 word_chars，多个短语以 | 分隔
+ * @return int 最少逆序对数量
+ * @return bool 是否存在字符相同的连续片段
+ */
+func chaos_min_inversions(query_word string, word_chars string) (int, bool) {
+	query_runes := []rune(strings.ReplaceAll(query_word, " ", ""))
+	size := len(query_runes)
+	if size == 0 {
+		return 0, false
+	}
+	query_counts := make(map[rune]int, size)
+	for _, r := range query_runes {
+		query_counts[r]++
+	}
+
+	best := -1
+	for _, phrase := range strings.Split(word_chars, "|") {
+		phrase_runes := []rune(strings.ReplaceAll(phrase, " ", ""))
+		for start := 0; start+size <= len(phrase_runes); start++ {
+			window := phrase_runes[start : start+size]
+			if !chaos_same_runes(window, query_counts) {
+				continue
+			}
+			if inversions := chaos_count_inversions(query_runes, window); best < 0 || inversions < best {
+				best = inversions
+			}
+		}
+	}
+	return best, best >= 0
+}
+
+func chaos_same_runes(window []rune, counts map[rune]int) bool {
+	window_counts := make(map[rune]int, len(window))
+	for _, r := range window {
+		window_counts[r]++
+		if window_counts[r] > counts[r] {
+			return false
+		}
+	}
+	return true
+}
+
+// 将查询中的每个 rune 依次对应到片段中相同 rune 最靠前的未用位置，统计位置序列的逆序对数量
+func chaos_count_inversions(query_runes []rune, window []rune) int {
+	positions := make(map[rune][]int, len(window))
+	for i, r := range window {
+		positions[r] = append(positions[r], i)
+	}
+	order := make([]int, 0, len(query_runes))
+	for _, r := range query_runes {
+		order = append(order, positions[r][0])
+		positions[r] = positions[r][1:]
+	}
+	inversions := 0
+	for i := 0; i < len(order); i++ {
+		for j := i + 1; j < len(order); j++ {
+			if order[i] > order[j] {
+				inversions++
+			}
+		}
+	}
+	return inversions
+}
@@ -0,0 +1,171 @@
+package radix
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func search_route_hit(r SearchResult, route RecallRoute) *RouteHit {
+	for i := range r.Routes {
+		if r.Routes[i].Route == route {
+			return &r.Routes[i]
+		}
+	}
+	return nil
+}
+
+// 关闭的路由不参与融合；融合得分为各路权重 / (k + 排名) 之和，按得分从高到低排序
+func TestSearchRouteOptions(t *testing.T) {
+	s := open_test_searcher(t, build_places_index(t, nil))
+	cases := []struct {
+		name    string
+		routes  map[RecallRoute]bool
+		weights map[RecallRoute]float64
+		rrf_k   float64
+	}{
+		{"default", nil, nil, 0},
+		{"char only", map[RecallRoute]bool{RoutePinyin: false, RouteMask: false, RouteChaos: false}, nil, 0},
+		{"no char", map[RecallRoute]bool{RouteChar: false}, nil, 0},
+		{"weighted", nil, map[RecallRoute]float64{RouteChar: 3, RouteChaos: 0.1}, 10},
+	}
+	for _, c := range cases {
+		opts := DefaultSearchOptions()
+		opts.Routes, opts.Weights, opts.RRFK = c.routes, c.weights, c.rrf_k
+		k := c.rrf_k
+		if k <= 0 {
+			k = 60
+		}
+
+		results, err := s.SearchWithOptions("中国银行", opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) == 0 {
+			t.Errorf("%s: no results", c.name)
+			continue
+		}
+		for i, r := range results {
+			score := 0.0
+			for _, hit := range r.Routes {
+				if !opts.IsRouteEnabled(hit.Route) {
+					t.Errorf("%s: %s was recalled by disabled route %s", c.name, r.Name, hit.Route)
+				}
+				score += opts.RouteWeight(hit.Route) / (k + float64(hit.Rank))
+			}
+			if math.Abs(score-r.Score) > 1e-9 {
+				t.Errorf("%s: %s scored %v, want %v", c.name, r.Name, r.Score, score)
+			}
+			if i > 0 && r.Score > results[i-1].Score {
+				t.Errorf("%s: %s ranked after a lower score", c.name, r.Name)
+			}
+		}
+		if c.name != "no char" && results[0].Name != "中国银行" {
+			t.Errorf("%s: top result %s, want 中国银行", c.name, results[0].Name)
+		}
+	}
+}
+
+// 查询中的错字落在掩码位置上时仍能召回，命中信息记录被掩码的位置及原字
+func TestSearchMaskRoute(t *testing.T) {
+	s := open_test_searcher(t, build_places_index(t, nil))
+	cases := []struct {
+		query    string
+		want     string
+		position int
+		char     string
+	}{
+		{"中国很行", "中国银行", 2, "很"},
+		{"银航大厦", "银行大厦", 1, "航"},
+	}
+	for _, c := range cases {
+		results, err := s.Search(c.query, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		i := slices.IndexFunc(results, func(r SearchResult) bool { return r.Name == c.want })
+		if i < 0 {
+			t.Errorf("Search(%s) = %v, want %s", c.query, search_result_names(results), c.want)
+			continue
+		}
+		hit := search_route_hit(results[i], RouteMask)
+		if hit == nil {
+			t.Errorf("Search(%s): %s was not recalled by the mask route", c.query, c.want)
+			continue
+		}
+		query_runes := []rune(c.query)
+		found := false
+		for _, m := range hit.Masks {
+			if len(m.Positions) != len(m.Chars) {
+				t.Fatalf("Search(%s): mask %+v has mismatched positions and chars", c.query, m)
+			}
+			for j, pos := range m.Positions {
+				if string(query_runes[pos]) != m.Chars[j] {
+					t.Errorf("Search(%s): mask %s position %d is %q, not %q", c.query, m.Word, pos, string(query_runes[pos]), m.Chars[j])
+				}
+				if pos == c.position && m.Chars[j] == c.char {
+					found = true
+				}
+			}
+		}
+		if !found {
+			t.Errorf("Search(%s): masks %+v do not cover %s at %d", c.query, hit.Masks, c.char, c.position)
+		}
+	}
+}
+
+func TestChaosMinInversions(t *testing.T) {
+	cases := []struct {
+		query      string
+		word_chars string
+		inversions int
+		ok         bool
+	}{
+		{"中国银行", "中国银行", 0, true},
+		{"国中银行", "中国银行", 1, true},
+		{"中国行银", "中国银行", 1, true},
+		{"行银国中", "中国银行", 6, true},
+		{"银 行", "哆啦a梦|中国银行", 0, true},
+		{"行银", "哆啦a梦|中国银行", 1, true},
+		{"中银", "中国银行", 0, false},
+		{"行行", "中国银行", 0, false},
+		{"", "中国银行", 0, false},
+	}
+	for _, c := range cases {
+		inversions, ok := chaos_min_inversions(c.query, c.word_chars)
+		if ok != c.ok || (ok && inversions != c.inversions) {
+			t.Errorf("chaos_min_inversions(%q, %q) = %d, %v, want %d, %v", c.query, c.word_chars, inversions, ok, c.inversions, c.ok)
+		}
+	}
+}
+
+// 乱序召回按逆序对数量降权，逆序过多的纯异序词不召回
+func TestSearchChaosRoute(t *testing.T) {
+	s := open_test_searcher(t, build_places_index(t, nil))
+	cases := []struct {
+		query   string
+		want    bool
+		max_dis int
+	}{
+		{"国中银行", true, 1},
+		{"中国行银", true, 1},
+		{"行银国中", false, 0},
+	}
+	for _, c := range cases {
+		results, err := s.Search(c.query, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var hit *RouteHit
+		if i := slices.IndexFunc(results, func(r SearchResult) bool { return r.Name == "中国银行" }); i >= 0 {
+			hit = search_route_hit(results[i], RouteChaos)
+		}
+		if (hit != nil) != c.want {
+			t.Errorf("Search(%s): chaos hit on 中国银行 = %+v, want %v", c.query, hit, c.want)
+			continue
+		}
+		if hit != nil && hit.Distance > c.max_dis {
+			t.Errorf("Search(%s): chaos distance %d, want at most %d", c.query, hit.Distance, c.max_dis)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package radix
+
+// 输入提示：在索引树 str_radix_nodes 中找到与输入前缀相同的节点，遍历其子孙节点，得到补全的索引词及字典词
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 每个补全词最多返回的字典词数量
+const suggestDictLimit = 10
+
+type Suggestion struct {
+	Word      string     `json:"word"`       // 补全的索引词
+	Type      int        `json:"type"`       // 索引词类型
+	Weight    int        `json:"weight"`     // 索引词的长度，与 index_words.word_len 相同，越小越接近输入前缀
+	DictWords []DictWord `json:"dict_words"` // 索引词对应的字典词
+}
+
+// 补全的候选索引词
+type suggest_candidate struct {
+	Word    string
+	Type    int
+	Weight  int
+	DictIDs []int
+}
+
+/**
+ * 按前缀补全索引词，用于搜索框的输入提示
+ * 1. 已加载内存索引时，直接取各类型索引树前缀节点缓存的字典词最多的索引词
+ * 2. 否则查找 hierarchy_key 与前缀相同的节点，按 parent_id 逐层遍历其子孙节点，节点的索引词包括 node_index_ids 中文字相同的其他索引词
+ * 3. 节点尚未建立父子关系（未执行 step5）或前缀不足两个字符时，按 hierarchy_key 的前缀范围查找
+ * 4. 掩码索引词、模糊拼音索引词以及字典词中并不存在的乱序索引词不作为补全
+ * 两种方式的候选按相同的规则排序：索引词长度从短到长，长度相同时字典词多的在前
+ * @param prefix 输入前缀
+ * @param k 返回的最大条数，<=0 时默认10条
+ * @return []Suggestion 按长度从短到长排序的补全词
+ */
+func (s *Searcher) Suggest(prefix string, k int) ([]Suggestion, error) {
+	if k <= 0 {
+		k = 10
+	}
+	prefix = strings.Join(strings.Fields(strings.ToLower(prefix)), " ")
+	if len(prefix) == 0 {
+		return []Suggestion{}, nil
+	}
+
+	// 过滤后可能不足k条，多取一些候选
+	candidate_limit := max(k*4, 40)
+	var candidates []suggest_candidate
+	var err error
+	if mi := s.memory.Load(); mi != nil {
+		candidates = mi.suggest_candidates(prefix, candidate_limit)
+	} else {
+		candidates, err = suggest_db_candidates(s.db, prefix, candidate_limit)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(candidates) == 0 {
+		return []Suggestion{}, nil
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Weight != candidates[j].Weight {
+			return candidates[i].Weight < candidates[j].Weight
+		}
+		ci, cj := len(candidates[i].DictIDs), len(candidates[j].DictIDs)
+		if ci != cj {
+			return ci > cj
+		}
+		if candidates[i].Word != candidates[j].Word {
+			return candidates[i].Word < candidates[j].Word
+		}
+		return candidates[i].Type < candidates[j].Type
+	})
+
+	dict_id_set := make(map[int]bool)
+	for _, c := range candidates {
+		for _, dict_id := range c.DictIDs {
+			dict_id_set[dict_id] = true
+		}
+	}
+	dict_ids := make([]int, 0, len(dict_id_set))
+	for dict_id := range dict_id_set {
+		dict_ids = append(dict_ids, dict_id)
+	}
+	dict_words, err := search_query_dict_words(s.db, dict_ids)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := make([]Suggestion, 0, k)
+	seen := make(map[string]int) // 补全词 -> 在 suggestions 中的位置
+	for _, c := range candidates {
+		pos, merged := seen[c.Word]
+		if !merged && len(suggestions) >= k {
+			continue
+		}
+		sort.Ints(c.DictIDs)
+		sg := Suggestion{Word: c.Word, Type: c.Type, Weight: c.Weight, DictWords: make([]DictWord, 0)}
+		if merged {
+			sg = suggestions[pos]
+		}
+		for _, dict_id := range c.DictIDs {
+			if len(sg.DictWords) >= suggestDictLimit {
+				break
+			}
+			dw, exists := dict_words[dict_id]
+			if !exists || suggest_has_dict_word(sg.DictWords, dict_id) {
+				continue
+			}
+			// 本身已按 rune 有序的字符索引词可能是乱序索引词，须校验字典词中确实包含该词
+			if c.Type == 0 && c.Word == sort_word_runes(c.Word) && !strings.Contains(dw.WordChars, c.Word) {
+				continue
+			}
+			sg.DictWords = append(sg.DictWords, dw)
+		}
+		// 文字相同的不同类型索引词（如字符索引词与拼音索引词）合并为一个补全词
+		if merged {
+			suggestions[pos] = sg
+			continue
+		}
+		if len(sg.DictWords) == 0 {
+			continue
+		}
+		seen[c.Word] = len(suggestions)
+		suggestions = append(suggestions, sg)
+	}
+	return suggestions, nil
+}
+
+func suggest_has_dict_word(dict_words []DictWord, dict_id int) bool {
+	for _, dw := range dict_words {
+		if dw.ID == dict_id {
+			return true
+		}
+	}
+	return false
+}
+
+// 从索引数据库的 str_radix_nodes 查找候选索引词
+func suggest_db_candidates(db *sqlx.DB, prefix string, limit int) ([]suggest_candidate, error) {
+	nodes, err := suggest_walk_descendants(db, prefix, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		nodes, err = suggest_scan_hierarchy_key(db, prefix, limit)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(nodes) == 0 {
+		return nil, nil
+	}
+
+	index_ids := make([]int, 0, len(nodes))
+	node_ids := make([]int, 0, len(nodes))
+	for _, n := range nodes {
+		index_ids = append(index_ids, n.IndexID)
+		node_ids = append(node_ids, n.ID)
+	}
+	// 文字相同的其他类型索引词记录在 node_index_ids 中
+	extras, err := suggest_query_node_index_ids(db, node_ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range extras {
+		index_ids = append(index_ids, e.IndexID)
+	}
+	index_words, err := search_query_index_words_by_ids(db, index_ids)
+	if err != nil {
+		return nil, err
+	}
+	index_dict_ids, err := search_query_index_dict_ids(db, index_ids)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]suggest_candidate, 0, len(index_words))
+	for _, iw := range index_words {
+		if iw.Type == 3 || strings.Contains(iw.Word, "*") {
+			continue
+		}
+		candidates = append(candidates, suggest_candidate{
+			Word:    iw.Word,
+			Type:    iw.Type,
+			Weight:  iw.WordLen,
+			DictIDs: index_dict_ids[iw.ID],
+		})
+	}
+	return candidates, nil
+}
+
+// 从与前缀相同的节点开始，按 parent_id 逐层遍历子孙节点，返回带有索引词的节点
+func suggest_walk_descendants(db *sqlx.DB, prefix string, limit int) ([]StrRadixNode, error) {
+	var roots []StrRadixNode
+	err := db.Select(&roots, "SELECT id, parent_id, key, hierarchy_key, index_id, weight, child_count FROM str_radix_nodes WHERE hierarchy_key = ?", prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query str_radix_nodes: %w", err)
+	}
+
+	results := make([]StrRadixNode, 0)
+	level := make([]int, 0, len(roots))
+	linked := false
+	for _, n := range roots {
+		if n.IndexID > 0 {
+			results = append(results, n)
+		}
+		if n.ChildCount > 0 {
+			linked = true
+			level = append(level, n.ID)
+		}
+	}
+	// 节点没有子节点记录时，可能只是尚未建立父子关系，交由调用方按前缀范围查找
+	if !linked {
+		return nil, nil
+	}
+
+	for len(level) > 0 && len(results) < limit {
+		next := make([]int, 0)
+		for i := 0; i < len(level); i += searchBatchSize {
+			end := min(i+searchBatchSize, len(level))
+			query, args, err := sqlx.In("SELECT id, parent_id, key, hierarchy_key, index_id, weight, child_count FROM str_radix_nodes WHERE parent_id IN (?)", level[i:end])
+			if err != nil {
+				return nil, fmt.Errorf("failed to build query: %w", err)
+			}
+			var children []StrRadixNode
+			if err := db.Select(&children, db.Rebind(query), args...); err != nil {
+				return nil, fmt.Errorf("failed to query str_radix_nodes: %w", err)
+			}
+			for _, c := range children {
+				if c.IndexID > 0 {
+					results = append(results, c)
+				}
+				if c.ChildCount > 0 {
+					next = append(next, c.ID)
+				}
+			}
+		}
+		level = next
+	}
+	return results, nil
+}
+
+// 按 hierarchy_key 的前缀范围查找带有索引词的节点，层级浅的在前
+func suggest_scan_hierarchy_key(db *sqlx.DB, prefix string, limit int) ([]StrRadixNode, error) {
+	var nodes []StrRadixNode
+	// 0xff 不会出现在 utf8 编码中，作为前缀范围的上界
+	err := db.Select(&nodes, "SELECT id, parent_id, key, hierarchy_key, index_id, weight, child_count FROM str_radix_nodes WHERE hierarchy_key >= ? AND hierarchy_key < ? AND index_id > 0 ORDER BY weight, hierarchy_key LIMIT ?", prefix, prefix+"\xff", limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan str_radix_nodes: %w", err)
+	}
+	return nodes, nil
+}
+
+// 按节点ID查询 node_index_ids
+func suggest_query_node_index_ids(db *sqlx.DB, node_ids []int) ([]NodeIndexID, error) {
+	results := make([]NodeIndexID, 0)
+	for i := 0; i < len(node_ids); i += searchBatchSize {
+		end := min(i+searchBatchSize, len(node_ids))
+
+		query, args, err := sqlx.In("SELECT id, node_id, index_id FROM node_index_ids WHERE node_id IN (?)", node_ids[i:end])
+		if err != nil {
+			return nil, fmt.Errorf("failed to build query: %w", err)
+		}
+
+		var batch []NodeIndexID
+		if err := db.Select(&batch, db.Rebind(query), args...); err != nil {
+			return nil, fmt.Errorf("failed to query node_index_ids: %w", err)
+		}
+		results = append(results, batch...)
+	}
+	return results, nil
+}
@@ -0,0 +1,57 @@
+package radix
+
+import (
+	"reflect"
+	"testing"
+)
+
+// 补全词只比较文字、类型、长度及字典词名称，字典词的其他字段与排序无关
+type suggest_summary struct {
+	Word   string
+	Type   int
+	Weight int
+	Names  []string
+}
+
+func suggest_summaries(t *testing.T, s *Searcher, prefix string) []suggest_summary {
+	t.Helper()
+	suggestions, err := s.Suggest(prefix, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := make([]suggest_summary, 0, len(suggestions))
+	for _, sg := range suggestions {
+		names := make([]string, 0, len(sg.DictWords))
+		for _, dw := range sg.DictWords {
+			names = append(names, dw.Name)
+		}
+		results = append(results, suggest_summary{Word: sg.Word, Type: sg.Type, Weight: sg.Weight, Names: names})
+	}
+	return results
+}
+
+// 加载内存索引前后，同一个前缀的补全结果及顺序相同
+func TestSuggestSameWithMemoryIndex(t *testing.T) {
+	s := open_test_searcher(t, build_test_index(t, map[string][]string{
+		"places": testPlaces,
+		"toys":   {"哆啦A梦", "哆啦美", "dlam", "宗国", "zong guo"},
+	}, nil))
+	prefixes := []string{"中国", "银行", "哆啦", "dl", "zhong", "zong", "chong qing", "yh"}
+
+	from_db := make(map[string][]suggest_summary)
+	for _, prefix := range prefixes {
+		from_db[prefix] = suggest_summaries(t, s, prefix)
+	}
+	if _, err := s.LoadMemoryIndex(); err != nil {
+		t.Fatal(err)
+	}
+	for _, prefix := range prefixes {
+		got := suggest_summaries(t, s, prefix)
+		if len(got) == 0 {
+			t.Errorf("Suggest(%s) returned nothing", prefix)
+		}
+		if !reflect.DeepEqual(got, from_db[prefix]) {
+			t.Errorf("Suggest(%s) differs after loading the memory index:\nmemory %v\n    db %v", prefix, got, from_db[prefix])
+		}
+	}
+}
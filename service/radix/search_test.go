@@ -0,0 +1,116 @@
+package radix
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// 在临时目录中写入字典文件，返回字典目录
+func write_test_dicts(t *testing.T, dicts map[string][]string) string {
+	t.Helper()
+	dict_dir := filepath.Join(t.TempDir(), "dict")
+	if err := os.MkdirAll(dict_dir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	for dict, names := range dicts {
+		file, err := os.Create(filepath.Join(dict_dir, dict+".csv"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := csv.NewWriter(file)
+		w.Write([]string{"name", "data"})
+		for _, name := range names {
+			w.Write([]string{name, "{}"})
+		}
+		w.Flush()
+		file.Close()
+		if err := w.Error(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dict_dir
+}
+
+// 在临时目录中写入字典文件并创建索引，返回索引数据库路径
+func build_test_index(t *testing.T, dicts map[string][]string, opts *IndexOptions) string {
+	t.Helper()
+	index_path, err := NewIndex(write_test_dicts(t, dicts), filepath.Join(t.TempDir(), "index"), "test", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return index_path
+}
+
+// 多个测试共用的地名字典
+var testPlaces = []string{"重庆市", "长沙市", "银行大厦", "中国银行", "中国人民银行"}
+
+func build_places_index(t *testing.T, opts *IndexOptions) string {
+	t.Helper()
+	return build_test_index(t, map[string][]string{"places": testPlaces}, opts)
+}
+
+func open_test_searcher(t *testing.T, index_path string) *Searcher {
+	t.Helper()
+	s, err := NewSearcher(index_path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func open_test_indexdb(t *testing.T, index_path string) *sqlx.DB {
+	t.Helper()
+	db, err := initialize_indexdb(index_path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func search_result_names(results []SearchResult) []string {
+	names := make([]string, 0, len(results))
+	for _, r := range results {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+func TestSearchRecallsDictWords(t *testing.T) {
+	index_path := build_places_index(t, nil)
+	s := open_test_searcher(t, index_path)
+
+	results, err := s.Search("中国银行", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) == 0 || results[0].Name != "中国银行" {
+		t.Fatalf("Search(中国银行) = %+v", results)
+	}
+}
+
+func TestSearchReturnsDictIdScanError(t *testing.T) {
+	index_path := build_places_index(t, nil)
+
+	db, err := initialize_indexdb(index_path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sqlite 不校验列类型，写入无法扫描为整数的 dict_id
+	_, err = db.Exec("UPDATE dict_index_ids SET dict_id = 'broken'")
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := open_test_searcher(t, index_path)
+	if results, err := s.Search("中国银行", 10); err == nil {
+		t.Fatalf("Search on corrupt dict_index_ids returned %d results without error", len(results))
+	}
+}
@@ -1,6 +1,8 @@
 package radix
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"math"
@@ -56,6 +58,7 @@ type StrRadixNode struct {
 	IndexID      int    `db:"index_id"`
 	Weight       int    `db:"weight"`
 	ChildCount   int    `db:"child_count"`
+	IndexIDs     []int  `db:"-"` // 同一节点上的其他索引词ID（如文字相同的不同类型索引词），写入 node_index_ids
 }
 
 func (srn *StrRadixNode) Merge(node *StrRadixNode) {
@@ -67,13 +70,23 @@ func (srn *StrRadixNode) Merge(node *StrRadixNode) {
 	}
 	if srn.IndexID == 0 {
 		srn.IndexID = node.IndexID
+	} else if node.IndexID > 0 && node.IndexID != srn.IndexID {
+		srn.IndexIDs = append(srn.IndexIDs, node.IndexID)
 	}
+	srn.IndexIDs = append(srn.IndexIDs, node.IndexIDs...)
 	if srn.Weight == 0 {
 		srn.Weight = node.Weight
 	}
 	srn.ChildCount += node.ChildCount
 }
 
+// 节点上除 index_id 以外的其他索引词
+type NodeIndexID struct {
+	ID      int `db:"id"`
+	NodeID  int `db:"node_id"`
+	IndexID int `db:"index_id"`
+}
+
 type IDRange struct {
 	MinId int
 	MaxId int
@@ -165,6 +178,14 @@ func initialize_indexdb(index_path string, create_table bool) (*sqlx.DB, error)
 	db.SetMaxIdleConns(0)
 	db.SetConnMaxLifetime(6 * time.Hour)
 
+	// 早于 index_metas 创建的旧索引，打开时补建该表
+	if !create_table {
+		if _, err := db.Exec(indexMetasTable); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to create table index_metas: %w", err)
+		}
+	}
+
 	// 定义各表和索引的 SQL 语句
 	if create_table {
 		statements := []string{
@@ -197,7 +218,8 @@ func initialize_indexdb(index_path string, create_table bool) (*sqlx.DB, error)
 			)`,
 
 			`CREATE UNIQUE INDEX "idx_index_words_word" ON "index_words" (
-				"word"
+				"word",
+				"type"
 			);`,
 
 			`CREATE TABLE "dict_index_ids" (
@@ -240,6 +262,8 @@ func initialize_indexdb(index_path string, create_table bool) (*sqlx.DB, error)
 			`CREATE INDEX "idx_node_index_ids_node_id" ON "node_index_ids" (
 				"node_id" ASC
 			)`,
+
+			indexMetasTable,
 		}
 
 		// 逐条执行 SQL 语句
@@ -253,6 +277,76 @@ func initialize_indexdb(index_path string, create_table bool) (*sqlx.DB, error)
 	return db, nil
 }
 
+const indexMetasTable = `CREATE TABLE IF NOT EXISTS "index_metas" (
+				"key"	TEXT NOT NULL UNIQUE,
+				"value"	TEXT NOT NULL DEFAULT '',
+				PRIMARY KEY("key")
+			)`
+
+// 索引元数据 index_metas 的键
+const (
+	metaIndexOptions = "index_options" // 创建索引时的参数 IndexOptions，JSON 格式
+	metaMemoryIndex  = "memory_index"  // .mem 内存索引文件的标记
+	metaDictDir      = "dict_dir"      // 创建索引时的字典目录
+	metaIndexStep    = "index_step"    // 创建索引最后完成的步骤
+)
+
+func write_index_meta(db *sqlx.DB, key string, value string) error {
+	_, err := db.Exec("INSERT OR REPLACE INTO index_metas (key, value) VALUES (?, ?)", key, value)
+	if err != nil {
+		return fmt.Errorf("failed to write index meta %s: %w", key, err)
+	}
+	return nil
+}
+
+/**
+ * 读取索引元数据
+ * @return bool 是否记录了该键，未记录时不是错误
+ * @return error 查询失败
+ */
+func read_index_meta(db *sqlx.DB, key string) (string, bool, error) {
+	var value string
+	err := db.Get(&value, "SELECT value FROM index_metas WHERE key = ?", key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read index meta %s: %w", key, err)
+	}
+	return value, true, nil
+}
+
+// 是否存在 index_metas 表，只读打开的旧索引无法补建该表
+func has_index_metas(db *sqlx.DB) (bool, error) {
+	var count int
+	err := db.Get(&count, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'index_metas'")
+	if err != nil {
+		return false, fmt.Errorf("failed to query sqlite_master: %w", err)
+	}
+	return count > 0, nil
+}
+
+// 清空表并重置自增ID，重新写入时ID与一次完成创建的索引一致
+func clear_index_tables(db *sqlx.DB, tables []string) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	for _, table := range tables {
+		if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %q", table)); err != nil {
+			return fmt.Errorf("failed to clear table %s: %w", table, err)
+		}
+		if _, err := tx.Exec("DELETE FROM sqlite_sequence WHERE name = ?", table); err != nil {
+			return fmt.Errorf("failed to reset sequence of %s: %w", table, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
 func ClearIndex(db *sqlx.DB) error {
 	_, err := db.Exec("DROP TABLE IF EXISTS index_words")
 	if err != nil {
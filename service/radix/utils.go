@@ -2,10 +2,7 @@ package radix
 
 import (
 	"regexp"
-	"strings"
 	"unicode"
-
-	"github.com/mozillazg/go-pinyin"
 )
 
 // func classifyRune(r rune) int {
@@ -125,30 +122,32 @@ import (
 // 	}
 // }
 
+/**
+ * 将词中的汉字转换为不带声调的拼音，拼音之间以空格分隔，非汉字部分保持原样
+ * 多音字取首选读音，常用词按短语读音表确定读音，全部读音见 AllPinyinOfWord
+ * @return py 拼音
+ * @return ok 所有汉字是否都转换成功
+ */
 func PinyinOfWord(word string) (py string, ok bool) {
-	// 汉字转拼音
-	args := pinyin.NewArgs()
-	args.Style = pinyin.Normal // 不带声调的拼音
+	pys, ok := AllPinyinOfWord(word, 1)
+	if len(pys) > 0 {
+		py = pys[0]
+	}
+	return py, ok
+}
 
-	pre_space := false
-	for _, r := range word {
-		if unicode.Is(unicode.Han, r) { // 判断是否为汉字
-			pinyinResult := pinyin.Pinyin(string(r), args)
-			if len(pinyinResult) > 0 && len(pinyinResult[0]) > 0 {
-				if !pre_space {
-					py += " "
-				}
-				py += pinyinResult[0][0] + " " // 只取第一个拼音
-				pre_space = true
-			} else {
-				ok = false
-			}
-		} else {
-			py += string(r) // 非汉字部分保持原样
-			pre_space = false
-		}
+/**
+ * 将词转换为拼音首字母，汉字取拼音的首字母，非汉字部分转小写并去除空白，如 "哆啦A梦" -> "dlam"
+ * 多音字取首选读音，全部读音见 AllPinyinInitialsOfWord
+ * @return abbr 拼音首字母
+ * @return ok 所有汉字是否都转换成功
+ */
+func PinyinInitialsOfWord(word string) (abbr string, ok bool) {
+	abbrs, ok := AllPinyinInitialsOfWord(word, 1)
+	if len(abbrs) > 0 {
+		abbr = abbrs[0]
 	}
-	return strings.TrimSpace(py), ok
+	return abbr, ok
 }
 
 /**